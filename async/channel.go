@@ -36,6 +36,29 @@ func NewRabbitmqChannel(opts ...mq.Option) (Channel, error) {
 	return &rabbitmqChannel{client: client, channel: channel}, nil
 }
 
+// NewConfirmChannel put the channel into confirm mode and records publisher confirmations
+// to the mq.Metrics set by mq.WithMetrics
+func NewConfirmChannel(opts ...mq.Option) (Channel, error) {
+	c, err := NewRabbitmqChannel(opts...)
+	if err != nil {
+		return nil, err
+	}
+	r := c.(*rabbitmqChannel)
+	if err = r.channel.Confirm(false); err != nil {
+		_ = r.Close()
+		return nil, fmt.Errorf("cann't put channel into confirm mode,%w", err)
+	}
+	confirms := r.channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+	metrics := r.client.Metrics()
+	go func() {
+		// 通道关闭时 confirms 会被关闭
+		for confirm := range confirms {
+			metrics.Confirm(confirm.Ack)
+		}
+	}()
+	return r, nil
+}
+
 type rabbitmqChannel struct {
 	client  *mq.Client
 	channel *amqp.Channel
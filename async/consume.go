@@ -3,6 +3,7 @@ package async
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/json-iterator/go"
 	"github.com/streadway/amqp"
@@ -24,6 +25,7 @@ func NewTaskConsumer(ctx context.Context, opts ...func(*ConsumerOption)) *taskCo
 			JSONHandler: jsoniter.ConfigCompatibleWithStandardLibrary,
 			ParamPool:   NewParamPool(),
 			Validator:   validator.NewValidator(),
+			Metrics:     mq.NopMetrics{},
 		},
 	}
 	for _, opt := range opts {
@@ -39,6 +41,7 @@ type ConsumerOption struct {
 	JSONHandler jsoniter.API
 	ParamPool   ParamPool // get Param
 	Validator   validator.Validator
+	Metrics     mq.Metrics
 }
 
 type taskConsumer struct {
@@ -104,45 +107,57 @@ func (t *taskConsumer) handle(ctx context.Context, d amqp.Delivery) error {
 	msgStruct, err := t.Marshal.Unmarshal(&d)
 	if err != nil {
 		logger.From(ctx).Error(err.Error())
-		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
-		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
-		if err = d.Reject(false); err != nil { // nolint:gocritic
-			return err
-		}
-		return nil
+		t.Metrics.Deliver(mq.UnknownName)
+		return t.reject(mq.UnknownName, &d)
 	}
 	logger.From(ctx).Sugar().Infof("consume uuid %s body:%s", msgStruct.UUID, msgStruct.Payload)
 	param := t.ParamPool.Get()
+	defer t.ParamPool.Put(param)
 	if err = t.JSONHandler.Unmarshal(msgStruct.Payload, param); err != nil {
 		logger.From(ctx).Error(err.Error())
-		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
-		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
-		if err = d.Reject(false); err != nil { // nolint:gocritic
-			return err
-		}
-		return nil
+		t.Metrics.Deliver(mq.UnknownName)
+		return t.reject(mq.UnknownName, &d)
 	}
+	name := param.Name
+	t.Metrics.Deliver(name)
 	if err = t.Validator.ValidateStruct(param); err != nil {
 		logger.From(ctx).Error(err.Error())
-		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
-		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
-		if err = d.Reject(false); err != nil { // nolint:gocritic
-			return err
-		}
-		return nil
+		return t.reject(name, &d)
 	}
+	t.Metrics.InFlight(name, 1)
+	start := time.Now()
 	err = t.Manager.Run(ctx, param)
-	t.ParamPool.Put(param)
+	t.Metrics.Handle(name, time.Since(start))
+	t.Metrics.InFlight(name, -1)
 	if err != nil {
 		logger.From(ctx).Error(err.Error())
-		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
-		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
-		if err = d.Reject(false); err != nil {
-			return err
-		}
-		return nil
+		return t.nack(name, &d)
 	}
 	// 手动确认收到本条消息, true表示回复当前信道所有未回复的ack，用于批量确认。
 	// false表示回复当前条目
-	return d.Ack(false)
+	if err = d.Ack(false); err != nil {
+		return err
+	}
+	t.Metrics.Ack(name)
+	return nil
+}
+
+func (t *taskConsumer) reject(name string, d *amqp.Delivery) error {
+	// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
+	// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
+	if err := d.Reject(false); err != nil {
+		return err
+	}
+	t.Metrics.Reject(name)
+	return nil
+}
+
+// nack rejects the delivery whose executor failed
+func (t *taskConsumer) nack(name string, d *amqp.Delivery) error {
+	// 执行失败不重新入队，与 reject 一致
+	if err := d.Nack(false, false); err != nil {
+		return err
+	}
+	t.Metrics.Nack(name)
+	return nil
 }
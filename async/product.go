@@ -2,6 +2,7 @@ package async
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"sync"
@@ -21,6 +22,7 @@ type ProducerOption struct {
 	JSONHandler jsoniter.API
 	ParamPool   ParamPool
 	Validator   validator.Validator
+	Metrics     mq.Metrics
 }
 
 type TaskProducer struct {
@@ -37,6 +39,7 @@ func NewTaskProducer(opts ...func(*ProducerOption)) *TaskProducer {
 			JSONHandler: jsoniter.ConfigCompatibleWithStandardLibrary,
 			ParamPool:   NewParamPool(),
 			Validator:   validator.NewValidator(),
+			Metrics:     mq.NopMetrics{},
 		},
 	}
 	for _, opt := range opts {
@@ -46,8 +49,17 @@ func NewTaskProducer(opts ...func(*ProducerOption)) *TaskProducer {
 }
 
 func (t *TaskProducer) Publish(ctx context.Context, channel Channel, routingKey string, param *Param) error {
+	if param == nil {
+		return errors.New("param is nil")
+	}
 	t.wg.Add(1)
 	defer t.wg.Done()
+	err := t.publish(channel, routingKey, param)
+	t.Metrics.Publish(param.Name, err)
+	return err
+}
+
+func (t *TaskProducer) publish(channel Channel, routingKey string, param *Param) error {
 	if err := t.Validator.ValidateStruct(param); err != nil {
 		return err
 	}
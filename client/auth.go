@@ -0,0 +1,286 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/crochee/lirity/logger"
+)
+
+// Token is an oauth2 access token
+type Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	// Expiry zero means the token never expires
+	Expiry time.Time
+}
+
+// Header returns the value of Authorization header
+func (t *Token) Header() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+func (t *Token) expired(early time.Duration) bool {
+	return !t.Expiry.IsZero() && time.Now().Add(early).After(t.Expiry)
+}
+
+// TokenSource returns a token, it is called every time a token is needed unless it is cached
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+type TokenSourceFunc func(ctx context.Context) (*Token, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// ClientCredentials obtains tokens by the oauth2 client credentials grant
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Client nil means DefaultClient
+	Client Client
+}
+
+func (c *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	return requestToken(ctx, c.Client, c.TokenURL, c.ClientID, c.ClientSecret, form)
+}
+
+// RefreshToken obtains tokens by the oauth2 refresh token grant,
+// the refresh token is replaced when the server rotates it
+type RefreshToken struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// Client nil means DefaultClient
+	Client Client
+
+	mutex        sync.Mutex
+	refreshToken string
+}
+
+func NewRefreshToken(tokenURL, clientID, clientSecret, refreshToken string) *RefreshToken {
+	return &RefreshToken{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		refreshToken: refreshToken,
+	}
+}
+
+func (r *RefreshToken) Token(ctx context.Context) (*Token, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	token, err := requestToken(ctx, r.Client, r.TokenURL, r.ClientID, r.ClientSecret, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {r.refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken != "" {
+		r.refreshToken = token.RefreshToken
+	}
+	return token, nil
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func requestToken(ctx context.Context, c Client, tokenURL, clientID, clientSecret string,
+	form url.Values) (*Token, error) {
+	if c == nil {
+		c = DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	var resp *http.Response
+	if resp, err = c.Do(req); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var body []byte
+	if body, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20)); err != nil {
+		return nil, err
+	}
+	var result tokenResponse
+	if err = jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("token endpoint returned %d,%w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || result.Error != "" || result.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned %d,%s %s",
+			resp.StatusCode, result.Error, result.ErrorDescription)
+	}
+	token := &Token{
+		AccessToken:  result.AccessToken,
+		TokenType:    result.TokenType,
+		RefreshToken: result.RefreshToken,
+	}
+	if result.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// NewCachedTokenSource caches the token of src until earlyRefresh before its expiry,
+// concurrent callers share one refresh
+func NewCachedTokenSource(src TokenSource, earlyRefresh time.Duration) *CachedTokenSource {
+	return &CachedTokenSource{src: src, earlyRefresh: earlyRefresh}
+}
+
+type CachedTokenSource struct {
+	src          TokenSource
+	earlyRefresh time.Duration
+
+	mutex sync.Mutex
+	token *Token
+}
+
+func (c *CachedTokenSource) Token(ctx context.Context) (*Token, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.token != nil && !c.token.expired(c.earlyRefresh) {
+		return c.token, nil
+	}
+	token, err := c.src.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.token = token
+	return token, nil
+}
+
+// Invalidate drops the cached token if it is still token,
+// so that concurrent rejected requests refresh only once
+func (c *CachedTokenSource) Invalidate(token *Token) {
+	c.mutex.Lock()
+	if c.token == token {
+		c.token = nil
+	}
+	c.mutex.Unlock()
+}
+
+// invalidate drops the cached token whose Authorization header is header, it reports whether it is dropped
+func (c *CachedTokenSource) invalidate(header string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.token == nil || c.token.Header() != header {
+		return false
+	}
+	c.token = nil
+	return true
+}
+
+// Auth sets Authorization header from src when it is absent, and retries once with a fresh token
+// when the response is 401 and the rejected token is obtained from src, including tokens injected by AuthHandler
+// with the same src. src which is not a *CachedTokenSource is cached by Auth.
+func Auth(src TokenSource) Middleware {
+	cached, ok := src.(*CachedTokenSource)
+	if !ok {
+		cached = NewCachedTokenSource(src, 0)
+	}
+	return func(next Client) Client {
+		return ClientFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			sent := req.Header.Get("Authorization")
+			injected := sent == ""
+			if injected {
+				token, err := cached.Token(ctx)
+				if err != nil {
+					return nil, err
+				}
+				sent = token.Header()
+				// 不修改调用方的请求
+				req = req.Clone(ctx)
+				req.Header.Set("Authorization", sent)
+			}
+			resp, err := next.Do(req)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+			if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+				return resp, nil
+			}
+			// 令牌可能已被并发的请求刷新，此时不再丢弃
+			if !cached.invalidate(sent) && !injected {
+				// 调用方自带的令牌不替换
+				return resp, nil
+			}
+			fresh, err := cached.Token(ctx)
+			if err != nil || fresh.Header() == sent {
+				return resp, nil
+			}
+			return retryAuth(next, req, resp, fresh.Header())
+		})
+	}
+}
+
+func retryAuth(next Client, req *http.Request, resp *http.Response, authorization string) (*http.Response, error) {
+	retry, err := rewind(req)
+	if err != nil {
+		return resp, nil
+	}
+	if retry == req {
+		retry = req.Clone(req.Context())
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	retry.Header.Set("Authorization", authorization)
+	return next.Do(retry)
+}
+
+// AuthHandler returns URLHandler whose Header injects the token of src into the headers,
+// the headers are returned without token when src fails
+func AuthHandler(handler URLHandler, src TokenSource) URLHandler {
+	return &authHandler{URLHandler: handler, src: src}
+}
+
+type authHandler struct {
+	URLHandler
+	src TokenSource
+}
+
+func (a *authHandler) Header(ctx context.Context, header http.Header) http.Header {
+	header = a.URLHandler.Header(ctx, header)
+	if header.Get("Authorization") != "" {
+		return header
+	}
+	token, err := a.src.Token(ctx)
+	if err != nil {
+		logger.From(ctx).Sugar().Warnf("get token failed,%v", err)
+		return header
+	}
+	header.Set("Authorization", token.Header())
+	return header
+}
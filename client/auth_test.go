@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCredentials(t *testing.T) {
+	var issued int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			id, secret, ok := r.BasicAuth()
+			require.True(t, ok)
+			require.Equal(t, "id", id)
+			require.Equal(t, "secret", secret)
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			require.Equal(t, "read write", r.PostForm.Get("scope"))
+			n := atomic.AddInt32(&issued, 1)
+			_, _ = w.Write([]byte(`{"access_token":"t` + strconv.Itoa(int(n)) + `","token_type":"bearer","expires_in":3600}`))
+		case "/v1":
+			// 第一个令牌被服务端吊销
+			if r.Header.Get("Authorization") != "Bearer t2" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	src := NewCachedTokenSource(&ClientCredentials{
+		TokenURL:     server.URL + "/token",
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	}, time.Minute)
+	c := Chain(NewStandardClient(), Auth(src))
+	for i := 0; i < 2; i++ {
+		req, err := NewRequest(context.Background(), http.MethodPost, server.URL+"/v1", []byte("hello"), nil)
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "hello", string(body))
+		require.Empty(t, req.Header.Get("Authorization"))
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&issued))
+
+	header := AuthHandler(NewURLHandler(), src).Header(context.Background(), nil)
+	require.Equal(t, "Bearer t2", header.Get("Authorization"))
+
+	// 调用方自带的令牌不重试
+	req, err := NewRequest(context.Background(), http.MethodGet, server.URL+"/v1", nil,
+		http.Header{"Authorization": []string{"Bearer other"}})
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, int32(2), atomic.LoadInt32(&issued))
+}
+
+func TestAuthTokenSourceFunc(t *testing.T) {
+	var issued int32
+	src := TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		n := atomic.AddInt32(&issued, 1)
+		return &Token{AccessToken: "t" + strconv.Itoa(int(n))}, nil
+	})
+	c := Chain(ClientFunc(func(req *http.Request) (*http.Response, error) {
+		// 第一个令牌被服务端吊销
+		if req.Header.Get("Authorization") != "Bearer t2" {
+			return &http.Response{StatusCode: http.StatusUnauthorized, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}), Auth(src))
+	for i := 0; i < 3; i++ {
+		req, err := NewRequest(context.Background(), http.MethodGet, "http://a.com/v1", nil, nil)
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&issued))
+}
+
+func TestCachedTokenSource(t *testing.T) {
+	var calls int32
+	src := NewCachedTokenSource(TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		atomic.AddInt32(&calls, 1)
+		return &Token{AccessToken: "t", Expiry: time.Now().Add(30 * time.Second)}, nil
+	}), 10*time.Second)
+	for i := 0; i < 3; i++ {
+		_, err := src.Token(context.Background())
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// 提前刷新
+	src = NewCachedTokenSource(src.src, time.Minute)
+	for i := 0; i < 2; i++ {
+		_, err := src.Token(context.Background())
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestRefreshToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		switch r.PostForm.Get("refresh_token") {
+		case "r1":
+			_, _ = w.Write([]byte(`{"access_token":"a1","refresh_token":"r2"}`))
+		case "r2":
+			_, _ = w.Write([]byte(`{"access_token":"a2"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		}
+	}))
+	defer server.Close()
+	src := NewRefreshToken(server.URL, "id", "secret", "r1")
+	for _, expected := range []string{"a1", "a2", "a2"} {
+		token, err := src.Token(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, expected, token.AccessToken)
+	}
+	_, err := NewRefreshToken(server.URL, "id", "secret", "bad").Token(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid_grant")
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Endpoint is a picked target of Balancer
+type Endpoint struct {
+	// Address like http://127.0.0.1:8080
+	Address string
+	Weight  int
+
+	outstanding  int64
+	fails        int32
+	ejectedUntil int64
+}
+
+// Outstanding returns the requests in flight on the endpoint
+func (e *Endpoint) Outstanding() int64 {
+	return atomic.LoadInt64(&e.outstanding)
+}
+
+// Balancer picks an endpoint from endpoints which is never empty
+type Balancer interface {
+	Pick(ctx context.Context, endpoints []*Endpoint) *Endpoint
+}
+
+type hashKey struct{}
+
+// WithHashKey set the key ConsistentHash balancer uses
+func WithHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKey{}, key)
+}
+
+// NewRoundRobin picks endpoints in turn
+func NewRoundRobin() Balancer {
+	return &roundRobin{}
+}
+
+type roundRobin struct {
+	next uint64
+}
+
+func (r *roundRobin) Pick(_ context.Context, endpoints []*Endpoint) *Endpoint {
+	n := atomic.AddUint64(&r.next, 1) - 1
+	return endpoints[n%uint64(len(endpoints))]
+}
+
+// NewWeightedRandom picks endpoints randomly in proportion to Weight, Weight <= 0 is treated as 1
+func NewWeightedRandom() Balancer {
+	return weightedRandom{}
+}
+
+type weightedRandom struct {
+}
+
+func (weightedRandom) Pick(_ context.Context, endpoints []*Endpoint) *Endpoint {
+	total := 0
+	for _, e := range endpoints {
+		total += weight(e)
+	}
+	n := rand.Intn(total) // nolint:gosec
+	for _, e := range endpoints {
+		if n -= weight(e); n < 0 {
+			return e
+		}
+	}
+	return endpoints[len(endpoints)-1]
+}
+
+func weight(e *Endpoint) int {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+// NewLeastOutstanding picks the endpoint with least requests in flight,
+// it works with DiscoveryHandler.Middleware which counts the requests
+func NewLeastOutstanding() Balancer {
+	return leastOutstanding{}
+}
+
+type leastOutstanding struct {
+}
+
+func (leastOutstanding) Pick(_ context.Context, endpoints []*Endpoint) *Endpoint {
+	// 从随机位置开始，避免并列时总是选中第一个
+	offset := rand.Intn(len(endpoints)) // nolint:gosec
+	picked := endpoints[offset]
+	for i := 1; i < len(endpoints); i++ {
+		e := endpoints[(offset+i)%len(endpoints)]
+		if e.Outstanding() < picked.Outstanding() {
+			picked = e
+		}
+	}
+	return picked
+}
+
+// NewConsistentHash picks endpoint by the key set by WithHashKey on a hash ring,
+// replicas is virtual nodes per endpoint; requests without key are picked randomly
+func NewConsistentHash(replicas int) Balancer {
+	if replicas <= 0 {
+		replicas = 100
+	}
+	return &consistentHash{replicas: replicas}
+}
+
+type consistentHash struct {
+	replicas int
+
+	mutex sync.Mutex
+	ring  []uint32
+	nodes map[uint32]string
+	built []*Endpoint
+}
+
+func (c *consistentHash) Pick(ctx context.Context, endpoints []*Endpoint) *Endpoint {
+	key, ok := ctx.Value(hashKey{}).(string)
+	if !ok {
+		return endpoints[rand.Intn(len(endpoints))] // nolint:gosec
+	}
+	c.mutex.Lock()
+	if !sameEndpoints(c.built, endpoints) {
+		c.build(endpoints)
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(c.ring), func(i int) bool { return c.ring[i] >= hash })
+	if i == len(c.ring) {
+		i = 0
+	}
+	address := c.nodes[c.ring[i]]
+	c.mutex.Unlock()
+	for _, e := range endpoints {
+		if e.Address == address {
+			return e
+		}
+	}
+	return endpoints[0]
+}
+
+func (c *consistentHash) build(endpoints []*Endpoint) {
+	c.ring = make([]uint32, 0, len(endpoints)*c.replicas)
+	c.nodes = make(map[uint32]string, len(endpoints)*c.replicas)
+	for _, e := range endpoints {
+		for i := 0; i < c.replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + e.Address))
+			c.ring = append(c.ring, hash)
+			c.nodes[hash] = e.Address
+		}
+	}
+	sort.Slice(c.ring, func(i, j int) bool { return c.ring[i] < c.ring[j] })
+	c.built = append(c.built[:0], endpoints...)
+}
+
+func sameEndpoints(a, b []*Endpoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,275 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen returned without sending the request when the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type State int32
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown state %d", s)
+	}
+}
+
+type BreakerOption struct {
+	// KeyFunc one breaker per key, default is request host
+	KeyFunc func(*http.Request) string
+	// Window length of the sliding window, which is divided into Buckets
+	Window  time.Duration
+	Buckets int
+	// MinRequests in the window before the rates are evaluated
+	MinRequests int
+	// FailureRate open the breaker when failures/total >= FailureRate, 0 means disabled
+	FailureRate float64
+	// SlowCall a call takes longer than it is slow
+	SlowCall time.Duration
+	// SlowCallRate open the breaker when slow/total >= SlowCallRate, 0 means disabled
+	SlowCallRate float64
+	// OpenTimeout how long to stay open before half-open
+	OpenTimeout time.Duration
+	// HalfOpenRequests trial calls permitted in half-open state,all must succeed to close
+	HalfOpenRequests int
+	// IsFailure default treats error and 5xx as failure
+	IsFailure     func(resp *http.Response, err error) bool
+	OnStateChange func(key string, from, to State)
+}
+
+// NewBreakerClient wraps c with circuit breakers
+func NewBreakerClient(c Client, opts ...func(*BreakerOption)) *breakerClient {
+	o := BreakerOption{
+		KeyFunc: func(req *http.Request) string {
+			return req.URL.Host
+		},
+		Window:           time.Minute,
+		Buckets:          10,
+		MinRequests:      20,
+		FailureRate:      0.5,
+		SlowCall:         5 * time.Second,
+		SlowCallRate:     1,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenRequests: 3,
+		IsFailure: func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= http.StatusInternalServerError
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Buckets < 1 {
+		o.Buckets = 1
+	}
+	if o.HalfOpenRequests < 1 {
+		o.HalfOpenRequests = 1
+	}
+	return &breakerClient{client: c, BreakerOption: o, breakers: make(map[string]*breaker)}
+}
+
+type breakerClient struct {
+	client Client
+	BreakerOption
+	mutex    sync.Mutex
+	breakers map[string]*breaker
+}
+
+func (b *breakerClient) Do(req *http.Request) (*http.Response, error) {
+	key := b.KeyFunc(req)
+	cb := b.get(key)
+	generation, err := cb.allow()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, key)
+	}
+	start := time.Now()
+	resp, err := b.client.Do(req)
+	cb.done(generation, b.IsFailure(resp, err), time.Since(start) >= b.SlowCall)
+	return resp, err
+}
+
+// State returns the state of the breaker of key
+func (b *breakerClient) State(key string) State {
+	b.mutex.Lock()
+	cb, ok := b.breakers[key]
+	b.mutex.Unlock()
+	if !ok {
+		return StateClosed
+	}
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return cb.state
+}
+
+func (b *breakerClient) get(key string) *breaker {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	cb, ok := b.breakers[key]
+	if !ok {
+		cb = &breaker{
+			key:     key,
+			option:  &b.BreakerOption,
+			buckets: make([]bucket, b.Buckets),
+		}
+		b.breakers[key] = cb
+	}
+	return cb
+}
+
+type bucket struct {
+	start    time.Time
+	total    int
+	failures int
+	slow     int
+}
+
+type breaker struct {
+	key    string
+	option *BreakerOption
+
+	mutex     sync.Mutex
+	state     State
+	openedAt  time.Time
+	buckets   []bucket
+	trials    int // half-open calls in flight or succeeded
+	successes int
+	changes   [][2]State
+	// generation changes with state, so that calls started in an old state are ignored
+	generation uint64
+}
+
+func (b *breaker) allow() (uint64, error) {
+	defer b.notify()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.option.OpenTimeout {
+			return 0, ErrCircuitOpen
+		}
+		b.setState(StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.trials >= b.option.HalfOpenRequests {
+			return 0, ErrCircuitOpen
+		}
+		b.trials++
+	}
+	return b.generation, nil
+}
+
+func (b *breaker) done(generation uint64, failure, slow bool) {
+	defer b.notify()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if generation != b.generation {
+		return
+	}
+	switch b.state {
+	case StateHalfOpen:
+		if failure || slow {
+			b.setState(StateOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.option.HalfOpenRequests {
+			b.setState(StateClosed)
+		}
+	case StateClosed:
+		cur := b.current(time.Now())
+		cur.total++
+		if failure {
+			cur.failures++
+		}
+		if slow {
+			cur.slow++
+		}
+		if b.tripped() {
+			b.setState(StateOpen)
+		}
+	}
+}
+
+// current returns the bucket of now, resetting it when it is out of the window
+func (b *breaker) current(now time.Time) *bucket {
+	width := b.option.Window / time.Duration(len(b.buckets))
+	if width <= 0 {
+		width = 1
+	}
+	start := now.Truncate(width)
+	cur := &b.buckets[int(now.UnixNano()/int64(width))%len(b.buckets)]
+	if !cur.start.Equal(start) {
+		*cur = bucket{start: start}
+	}
+	return cur
+}
+
+func (b *breaker) tripped() bool {
+	var total, failures, slow int
+	since := time.Now().Add(-b.option.Window)
+	for _, v := range b.buckets {
+		if v.start.Before(since) {
+			continue
+		}
+		total += v.total
+		failures += v.failures
+		slow += v.slow
+	}
+	if total == 0 || total < b.option.MinRequests {
+		return false
+	}
+	if b.option.FailureRate > 0 && float64(failures)/float64(total) >= b.option.FailureRate {
+		return true
+	}
+	return b.option.SlowCallRate > 0 && float64(slow)/float64(total) >= b.option.SlowCallRate
+}
+
+func (b *breaker) setState(state State) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.trials = 0
+	b.successes = 0
+	switch state {
+	case StateOpen:
+		b.openedAt = time.Now()
+	case StateClosed:
+		for i := range b.buckets {
+			b.buckets[i] = bucket{}
+		}
+	}
+	if from != state {
+		b.changes = append(b.changes, [2]State{from, state})
+	}
+}
+
+// notify calls OnStateChange outside the lock
+func (b *breaker) notify() {
+	b.mutex.Lock()
+	changes := b.changes
+	b.changes = nil
+	b.mutex.Unlock()
+	if b.option.OnStateChange == nil {
+		return
+	}
+	for _, change := range changes {
+		b.option.OnStateChange(b.key, change[0], change[1])
+	}
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type doFunc func(req *http.Request) (*http.Response, error)
+
+func (f doFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestBreakerClient(t *testing.T) {
+	var (
+		fail    = true
+		changes []string
+	)
+	c := NewBreakerClient(doFunc(func(req *http.Request) (*http.Response, error) {
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}), func(o *BreakerOption) {
+		o.MinRequests = 4
+		o.FailureRate = 0.5
+		o.OpenTimeout = 20 * time.Millisecond
+		o.HalfOpenRequests = 2
+		o.OnStateChange = func(key string, from, to State) {
+			changes = append(changes, key+":"+from.String()+"->"+to.String())
+		}
+	})
+	do := func(uri string) error {
+		req, err := NewRequest(context.Background(), http.MethodGet, uri, nil, nil)
+		require.NoError(t, err)
+		_, err = c.Do(req)
+		return err
+	}
+	for i := 0; i < 4; i++ {
+		require.Error(t, do("http://a.com/v1"))
+	}
+	require.Equal(t, StateOpen, c.State("a.com"))
+	require.True(t, errors.Is(do("http://a.com/v1"), ErrCircuitOpen))
+	// other host has its own breaker
+	require.False(t, errors.Is(do("http://b.com/v1"), ErrCircuitOpen))
+	require.Equal(t, StateClosed, c.State("b.com"))
+
+	time.Sleep(30 * time.Millisecond)
+	fail = false
+	require.NoError(t, do("http://a.com/v1"))
+	require.Equal(t, StateHalfOpen, c.State("a.com"))
+	require.NoError(t, do("http://a.com/v1"))
+	require.Equal(t, StateClosed, c.State("a.com"))
+	require.Equal(t, []string{
+		"a.com:closed->open",
+		"a.com:open->half-open",
+		"a.com:half-open->closed",
+	}, changes)
+}
@@ -0,0 +1,450 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crochee/lirity/logger"
+)
+
+// CacheHeader is set to "HIT", "REVALIDATED" or "STALE" on responses served from cache
+const CacheHeader = "X-Cache"
+
+const varyHeaderPrefix = "X-Cache-Vary-"
+
+type CacheOption struct {
+	Store CacheStore
+	// Shared cache ignores private responses and prefers s-maxage, the responses of requests with Authorization
+	// are stored only when they are public, s-maxage or must-revalidate
+	Shared bool
+	// RevalidateTimeout bounds the background revalidation of stale-while-revalidate
+	RevalidateTimeout time.Duration
+	// MaxEntry responses whose body is larger are passed through without caching, 0 means no limit
+	MaxEntry int64
+}
+
+// NewCacheClient wraps c with a RFC 7234 cache of GET responses, default store is 64MB in memory
+// and the entries are at most 1MB
+func NewCacheClient(c Client, opts ...func(*CacheOption)) Client {
+	o := CacheOption{
+		Store:             NewMemoryCache(64 << 20),
+		RevalidateTimeout: 30 * time.Second,
+		MaxEntry:          1 << 20,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &cacheClient{client: c, CacheOption: o, revalidating: make(map[string]struct{})}
+}
+
+type cacheClient struct {
+	client Client
+	CacheOption
+
+	mutex        sync.Mutex
+	revalidating map[string]struct{}
+}
+
+func (c *cacheClient) Do(req *http.Request) (*http.Response, error) {
+	key := cacheKey(req)
+	if req.Method != http.MethodGet {
+		resp, err := c.client.Do(req)
+		if err == nil && req.Method != http.MethodHead && req.Method != http.MethodOptions &&
+			resp.StatusCode < http.StatusBadRequest {
+			// 非安全方法成功后缓存失效，其他凭证的缓存无法枚举，只能等待过期
+			c.Store.Delete(req.URL.String())
+			c.Store.Delete(key)
+		}
+		return resp, err
+	}
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		return c.client.Do(req)
+	}
+	entry, ok := c.load(key, req)
+	if !ok {
+		return c.fetch(req, key)
+	}
+	age := entry.age()
+	lifetime := entry.lifetime(c.Shared)
+	_, noCache := reqCC["no-cache"]
+	_, respNoCache := entry.control["no-cache"]
+	if !noCache && !respNoCache {
+		if reqCC.fresh(age, lifetime) {
+			return entry.serve(req, "HIT", age), nil
+		}
+		if reqCC.acceptStale(entry.control, age, lifetime) {
+			return entry.serve(req, "STALE", age), nil
+		}
+		if swr, ok := entry.control.duration("stale-while-revalidate"); ok && age < lifetime+swr {
+			c.backgroundRevalidate(req, key, entry)
+			return entry.serve(req, "STALE", age), nil
+		}
+	}
+	return c.revalidate(req, key, entry)
+}
+
+// fetch sends req and stores the response when it is cacheable
+func (c *cacheClient) fetch(req *http.Request, key string) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !c.cacheable(req, resp) {
+		return resp, nil
+	}
+	return c.store(req, key, resp)
+}
+
+func (c *cacheClient) revalidate(req *http.Request, key string, entry *cacheEntry) (*http.Response, error) {
+	conditional := req.Clone(req.Context())
+	if etag := entry.response.Header.Get("ETag"); etag != "" {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if modified := entry.response.Header.Get("Last-Modified"); modified != "" {
+		conditional.Header.Set("If-Modified-Since", modified)
+	}
+	resp, err := c.client.Do(conditional)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusNotModified {
+		if !c.cacheable(req, resp) {
+			c.Store.Delete(key)
+			return resp, nil
+		}
+		return c.store(req, key, resp)
+	}
+	_ = resp.Body.Close()
+	// 304 更新缓存的头部与存储时间，entry可能正被并发读取，不能原地修改
+	updated := *entry.response
+	updated.Header = entry.response.Header.Clone()
+	for name, values := range resp.Header {
+		updated.Header[name] = values
+	}
+	fresh := &cacheEntry{
+		storedAt: time.Now(),
+		response: &updated,
+		body:     entry.body,
+		control:  parseCacheControl(updated.Header),
+	}
+	if data, err := encodeEntry(fresh.storedAt, fresh.response, fresh.body); err == nil {
+		c.Store.Set(key, data)
+	}
+	return fresh.serve(req, "REVALIDATED", 0), nil
+}
+
+func (c *cacheClient) backgroundRevalidate(req *http.Request, key string, entry *cacheEntry) {
+	c.mutex.Lock()
+	if _, ok := c.revalidating[key]; ok {
+		c.mutex.Unlock()
+		return
+	}
+	c.revalidating[key] = struct{}{}
+	c.mutex.Unlock()
+	log := logger.From(req.Context())
+	// 与原请求的生命周期解耦
+	ctx, cancel := context.WithTimeout(logger.With(context.Background(), log), c.RevalidateTimeout)
+	background := req.Clone(ctx)
+	go func() {
+		defer func() {
+			cancel()
+			c.mutex.Lock()
+			delete(c.revalidating, key)
+			c.mutex.Unlock()
+		}()
+		resp, err := c.revalidate(background, key, entry)
+		if err != nil {
+			log.Sugar().Warnf("revalidate %s failed,%v", key, err)
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+}
+
+// cacheKey is the url, responses of the requests with credential are stored under the key of the credential
+// so that they are never served to the requests with other credentials
+func cacheKey(req *http.Request) string {
+	key := req.URL.String()
+	if authorization := req.Header.Get("Authorization"); authorization != "" {
+		sum := sha256.Sum256([]byte(authorization))
+		key += " " + hex.EncodeToString(sum[:])
+	}
+	return key
+}
+
+func (c *cacheClient) cacheable(req *http.Request, resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone,
+		http.StatusRequestURITooLong, http.StatusNotImplemented:
+	default:
+		return false
+	}
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if _, ok := cc["private"]; ok && c.Shared {
+		return false
+	}
+	if req.Header.Get("Authorization") != "" && c.Shared {
+		// RFC 7234 3.2 共享缓存只存储明确允许的认证请求响应
+		_, public := cc["public"]
+		_, sMaxAge := cc["s-maxage"]
+		_, mustRevalidate := cc["must-revalidate"]
+		if !public && !sMaxAge && !mustRevalidate {
+			return false
+		}
+	}
+	if resp.Header.Get("Vary") == "*" {
+		return false
+	}
+	if _, ok := cc["max-age"]; ok {
+		return true
+	}
+	if _, ok := cc["s-maxage"]; ok && c.Shared {
+		return true
+	}
+	_, noCache := cc["no-cache"]
+	return noCache || resp.Header.Get("Expires") != "" || resp.Header.Get("ETag") != "" ||
+		resp.Header.Get("Last-Modified") != ""
+}
+
+// store reads the body, saves the response and returns a response with the body in memory,
+// the response whose body is larger than MaxEntry is returned as it is and the stored one is dropped
+func (c *cacheClient) store(req *http.Request, key string, resp *http.Response) (*http.Response, error) {
+	if c.MaxEntry > 0 && resp.ContentLength > c.MaxEntry {
+		c.Store.Delete(key)
+		return resp, nil
+	}
+	var reader io.Reader = resp.Body
+	if c.MaxEntry > 0 {
+		reader = io.LimitReader(resp.Body, c.MaxEntry+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	if c.MaxEntry > 0 && int64(len(body)) > c.MaxEntry {
+		c.Store.Delete(key)
+		// 已读取的部分放回
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	stored := *resp
+	stored.Header = resp.Header.Clone()
+	for _, name := range varyHeaders(resp.Header) {
+		stored.Header.Set(varyHeaderPrefix+name, req.Header.Get(name))
+	}
+	var data []byte
+	if data, err = encodeEntry(time.Now(), &stored, body); err == nil {
+		c.Store.Set(key, data)
+	}
+	return resp, nil
+}
+
+func (c *cacheClient) load(key string, req *http.Request) (*cacheEntry, bool) {
+	data, ok := c.Store.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entry, err := decodeEntry(data, req)
+	if err != nil {
+		c.Store.Delete(key)
+		return nil, false
+	}
+	for _, name := range varyHeaders(entry.response.Header) {
+		if entry.response.Header.Get(varyHeaderPrefix+name) != req.Header.Get(name) {
+			return nil, false
+		}
+	}
+	return entry, true
+}
+
+type cacheEntry struct {
+	storedAt time.Time
+	response *http.Response
+	body     []byte
+	control  cacheControl
+}
+
+// age is the current age of the response
+func (e *cacheEntry) age() time.Duration {
+	age := time.Since(e.storedAt)
+	if seconds, err := strconv.Atoi(e.response.Header.Get("Age")); err == nil && seconds > 0 {
+		age += time.Duration(seconds) * time.Second
+	}
+	return age
+}
+
+// lifetime is the freshness lifetime of the response
+func (e *cacheEntry) lifetime(shared bool) time.Duration {
+	if shared {
+		if d, ok := e.control.duration("s-maxage"); ok {
+			return d
+		}
+	}
+	if d, ok := e.control.duration("max-age"); ok {
+		return d
+	}
+	date, err := http.ParseTime(e.response.Header.Get("Date"))
+	if err != nil {
+		date = e.storedAt
+	}
+	if expires := e.response.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(date)
+	}
+	// 启发式过期时间：距上次修改时间的10%
+	if modified, err := http.ParseTime(e.response.Header.Get("Last-Modified")); err == nil && date.After(modified) {
+		return date.Sub(modified) / 10
+	}
+	return 0
+}
+
+func (e *cacheEntry) serve(req *http.Request, status string, age time.Duration) *http.Response {
+	resp := *e.response
+	resp.Header = e.response.Header.Clone()
+	for name := range resp.Header {
+		if strings.HasPrefix(name, varyHeaderPrefix) {
+			delete(resp.Header, name)
+		}
+	}
+	resp.Header.Set(CacheHeader, status)
+	resp.Header.Set("Age", strconv.Itoa(int(age.Seconds())))
+	resp.Body = io.NopCloser(bytes.NewReader(e.body))
+	resp.ContentLength = int64(len(e.body))
+	resp.Request = req
+	return &resp
+}
+
+// encodeEntry writes storedAt followed by the http/1.1 dump of the response
+func encodeEntry(storedAt time.Time, resp *http.Response, body []byte) ([]byte, error) {
+	dumped := *resp
+	dumped.Body = io.NopCloser(bytes.NewReader(body))
+	dumped.ContentLength = int64(len(body))
+	dumped.TransferEncoding = nil
+	dump, err := httputil.DumpResponse(&dumped, true)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 8, 8+len(dump))
+	binary.BigEndian.PutUint64(data, uint64(storedAt.UnixNano()))
+	return append(data, dump...), nil
+}
+
+func decodeEntry(data []byte, req *http.Request) (*cacheEntry, error) {
+	if len(data) < 8 {
+		return nil, errors.New("invalid cache entry")
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data[8:])), req)
+	if err != nil {
+		return nil, err
+	}
+	var body []byte
+	if body, err = io.ReadAll(resp.Body); err != nil {
+		return nil, err
+	}
+	return &cacheEntry{
+		storedAt: time.Unix(0, int64(binary.BigEndian.Uint64(data))),
+		response: resp,
+		body:     body,
+		control:  parseCacheControl(resp.Header),
+	}, nil
+}
+
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" && name != "*" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, arg := directive, ""
+			if i := strings.IndexByte(directive, '='); i >= 0 {
+				name, arg = directive[:i], strings.Trim(directive[i+1:], `"`)
+			}
+			cc[strings.ToLower(name)] = arg
+		}
+	}
+	return cc
+}
+
+// fresh reports whether the response of age and lifetime satisfies max-age and min-fresh of the request
+func (c cacheControl) fresh(age, lifetime time.Duration) bool {
+	if maxAge, ok := c.duration("max-age"); ok && age > maxAge {
+		return false
+	}
+	minFresh, _ := c.duration("min-fresh")
+	return age+minFresh < lifetime
+}
+
+// acceptStale reports whether the stale response is allowed by max-stale of the request,
+// max-stale without value accepts any staleness
+func (c cacheControl) acceptStale(response cacheControl, age, lifetime time.Duration) bool {
+	value, ok := c["max-stale"]
+	if !ok {
+		return false
+	}
+	if _, ok = response["must-revalidate"]; ok {
+		return false
+	}
+	if maxAge, ok := c.duration("max-age"); ok && age > maxAge {
+		return false
+	}
+	if value == "" {
+		return true
+	}
+	maxStale, ok := c.duration("max-stale")
+	return ok && age < lifetime+maxStale
+}
+
+func (c cacheControl) duration(name string) (time.Duration, bool) {
+	value, ok := c[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
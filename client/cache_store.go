@@ -0,0 +1,135 @@
+package client
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStore stores serialized responses of CacheClient
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// NewMemoryCache returns a LRU CacheStore holding at most maxBytes of values
+func NewMemoryCache(maxBytes int64) CacheStore {
+	return &memoryCache{
+		maxBytes: maxBytes,
+		list:     list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+type memoryCache struct {
+	mutex    sync.Mutex
+	maxBytes int64
+	size     int64
+	list     *list.List
+	items    map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	value []byte
+}
+
+func (m *memoryCache) Get(key string) ([]byte, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	e, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	m.list.MoveToFront(e)
+	return e.Value.(*memoryItem).value, true
+}
+
+func (m *memoryCache) Set(key string, value []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if int64(len(value)) > m.maxBytes {
+		m.remove(key)
+		return
+	}
+	if e, ok := m.items[key]; ok {
+		item := e.Value.(*memoryItem)
+		m.size += int64(len(value) - len(item.value))
+		item.value = value
+		m.list.MoveToFront(e)
+	} else {
+		m.items[key] = m.list.PushFront(&memoryItem{key: key, value: value})
+		m.size += int64(len(value))
+	}
+	for m.size > m.maxBytes {
+		m.remove(m.list.Back().Value.(*memoryItem).key)
+	}
+}
+
+func (m *memoryCache) Delete(key string) {
+	m.mutex.Lock()
+	m.remove(key)
+	m.mutex.Unlock()
+}
+
+func (m *memoryCache) remove(key string) {
+	e, ok := m.items[key]
+	if !ok {
+		return
+	}
+	m.list.Remove(e)
+	delete(m.items, key)
+	m.size -= int64(len(e.Value.(*memoryItem).value))
+}
+
+// NewDiskCache returns CacheStore saving every value to a file under dir
+func NewDiskCache(dir string) (CacheStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &diskCache{dir: dir}, nil
+}
+
+type diskCache struct {
+	dir string
+}
+
+func (d *diskCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (d *diskCache) Set(key string, value []byte) {
+	// 先写临时文件再重命名，避免读到写了一半的文件
+	f, err := os.CreateTemp(d.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(value)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return
+	}
+	if err = os.Rename(f.Name(), d.path(key)); err != nil {
+		_ = os.Remove(f.Name())
+	}
+}
+
+func (d *diskCache) Delete(key string) {
+	_ = os.Remove(d.path(key))
+}
+
+func (d *diskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
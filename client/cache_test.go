@@ -0,0 +1,246 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheClient(t *testing.T) {
+	var hits, validations int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&validations, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		_, _ = w.Write([]byte("body of " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	disk, err := NewDiskCache(dir)
+	require.NoError(t, err)
+	for name, store := range map[string]CacheStore{"memory": NewMemoryCache(1 << 20), "disk": disk} {
+		t.Run(name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+			atomic.StoreInt32(&validations, 0)
+			c := NewCacheClient(NewStandardClient(), func(o *CacheOption) { o.Store = store })
+			get := func(path string) (string, string) {
+				req, err := NewRequest(context.Background(), http.MethodGet, server.URL+path, nil, nil)
+				require.NoError(t, err)
+				resp, err := c.Do(req)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				return string(body), resp.Header.Get(CacheHeader)
+			}
+			for _, path := range []string{"/fresh", "/etag", "/nostore"} {
+				body, status := get(path)
+				require.Equal(t, "body of "+path, body)
+				require.Equal(t, "", status)
+			}
+			require.Equal(t, int32(3), atomic.LoadInt32(&hits))
+
+			body, status := get("/fresh")
+			require.Equal(t, "body of /fresh", body)
+			require.Equal(t, "HIT", status)
+			require.Equal(t, int32(3), atomic.LoadInt32(&hits))
+
+			body, status = get("/etag")
+			require.Equal(t, "body of /etag", body)
+			require.Equal(t, "REVALIDATED", status)
+			require.Equal(t, int32(1), atomic.LoadInt32(&validations))
+
+			_, status = get("/nostore")
+			require.Equal(t, "", status)
+			require.Equal(t, int32(5), atomic.LoadInt32(&hits))
+
+			// unsafe method invalidates
+			req, err := NewRequest(context.Background(), http.MethodPut, server.URL+"/fresh", nil, nil)
+			require.NoError(t, err)
+			resp, err := c.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			_, status = get("/fresh")
+			require.Equal(t, "", status)
+		})
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	var version int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		_, _ = w.Write([]byte{byte('0' + atomic.AddInt32(&version, 1))})
+	}))
+	defer server.Close()
+	c := NewCacheClient(NewStandardClient())
+	get := func() (string, string) {
+		req, err := NewRequest(context.Background(), http.MethodGet, server.URL, nil, nil)
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body), resp.Header.Get(CacheHeader)
+	}
+	body, _ := get()
+	require.Equal(t, "1", body)
+	body, status := get()
+	require.Equal(t, "1", body)
+	require.Equal(t, "STALE", status)
+	require.Eventually(t, func() bool {
+		body, _ := get()
+		return body == "2"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCacheAuthorization(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", r.URL.Query().Get("cc"))
+		_, _ = w.Write([]byte("body of " + r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	for _, shared := range []bool{false, true} {
+		atomic.StoreInt32(&hits, 0)
+		c := NewCacheClient(NewStandardClient(), func(o *CacheOption) { o.Shared = shared })
+		get := func(cc, token string) (string, string) {
+			req, err := NewRequest(context.Background(), http.MethodGet, server.URL+"?cc="+cc, nil,
+				http.Header{"Authorization": []string{token}})
+			require.NoError(t, err)
+			resp, err := c.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			return string(body), resp.Header.Get(CacheHeader)
+		}
+		for _, token := range []string{"Bearer a", "Bearer b", "Bearer a", "Bearer b"} {
+			body, _ := get("max-age=60", token)
+			require.Equal(t, "body of "+token, body)
+		}
+		body, status := get("max-age=60", "Bearer a")
+		require.Equal(t, "body of Bearer a", body)
+		if shared {
+			// 共享缓存不存储未声明public的认证响应
+			require.Equal(t, "", status)
+			require.Equal(t, int32(5), atomic.LoadInt32(&hits))
+		} else {
+			require.Equal(t, "HIT", status)
+			require.Equal(t, int32(2), atomic.LoadInt32(&hits))
+		}
+
+		atomic.StoreInt32(&hits, 0)
+		for _, token := range []string{"Bearer a", "Bearer b", "Bearer a", "Bearer b"} {
+			body, _ = get("public,max-age=60", token)
+			require.Equal(t, "body of "+token, body)
+		}
+		require.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	}
+}
+
+func TestCacheRequestDirectives(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", r.URL.Query().Get("cc"))
+		_, _ = w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	c := NewCacheClient(NewStandardClient())
+	get := func(cc, reqCC string) string {
+		req, err := NewRequest(context.Background(), http.MethodGet, server.URL+"?cc="+cc, nil,
+			http.Header{"Cache-Control": []string{reqCC}})
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.Header.Get(CacheHeader)
+	}
+	for _, tt := range []struct {
+		cc, reqCC, status string
+	}{
+		{cc: "max-age=60", reqCC: "", status: ""},
+		{cc: "max-age=60", reqCC: "", status: "HIT"},
+		{cc: "max-age=60", reqCC: "max-age=0", status: ""},
+		{cc: "max-age=60", reqCC: "min-fresh=120", status: ""},
+		{cc: "max-age=60", reqCC: "min-fresh=30", status: "HIT"},
+		{cc: "max-age=0", reqCC: "", status: ""},
+		{cc: "max-age=0", reqCC: "max-stale", status: "STALE"},
+		{cc: "max-age=0", reqCC: "max-stale=60", status: "STALE"},
+		{cc: "max-age=0", reqCC: "max-stale=60, max-age=0", status: ""},
+		{cc: "max-age=0, must-revalidate", reqCC: "", status: ""},
+		{cc: "max-age=0, must-revalidate", reqCC: "max-stale", status: ""},
+	} {
+		require.Equal(t, tt.status, get(tt.cc, tt.reqCC), "%s %s", tt.cc, tt.reqCC)
+	}
+}
+
+func TestCacheMaxEntry(t *testing.T) {
+	var hits int32
+	content := strings.Repeat("x", 64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Query().Get("chunked") != "" {
+			// 先flush使长度未知
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer server.Close()
+	c := NewCacheClient(NewStandardClient(), func(o *CacheOption) { o.MaxEntry = 16 })
+	for _, query := range []string{"", "?chunked=1"} {
+		atomic.StoreInt32(&hits, 0)
+		for i := 0; i < 2; i++ {
+			req, err := NewRequest(context.Background(), http.MethodGet, server.URL+query, nil, nil)
+			require.NoError(t, err)
+			resp, err := c.Do(req)
+			require.NoError(t, err)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, content, string(body))
+			require.Equal(t, "", resp.Header.Get(CacheHeader))
+		}
+		require.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	}
+}
+
+func TestMemoryCacheEvict(t *testing.T) {
+	m := NewMemoryCache(10)
+	m.Set("a", []byte("12345"))
+	m.Set("b", []byte("12345"))
+	_, ok := m.Get("a")
+	require.True(t, ok)
+	m.Set("c", []byte("1"))
+	_, ok = m.Get("b")
+	require.False(t, ok)
+	_, ok = m.Get("a")
+	require.True(t, ok)
+}
@@ -0,0 +1,282 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ErrInteractionNotFound is returned by a replaying Cassette when no recorded interaction matches the request
+var ErrInteractionNotFound = errors.New("no recorded interaction matches the request")
+
+type CassetteMode int
+
+const (
+	// ModeReplay only replays recorded interactions, the cassette file must exist
+	ModeReplay CassetteMode = iota
+	// ModeRecord sends every request and records it, replacing the previous recordings
+	ModeRecord
+	// ModeReplayOrRecord replays matched interactions and records the others
+	ModeReplayOrRecord
+)
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Matcher reports whether the scrubbed request matches the recorded one
+type Matcher func(req, recorded *RecordedRequest) bool
+
+// MatchMethod matches the request method
+func MatchMethod(req, recorded *RecordedRequest) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchURL matches the full url including the query
+func MatchURL(req, recorded *RecordedRequest) bool {
+	return req.URL == recorded.URL
+}
+
+// MatchBody matches the body, json bodies are compared after normalization
+// so that the order of keys and whitespaces are ignored
+func MatchBody(req, recorded *RecordedRequest) bool {
+	if req.Body == recorded.Body {
+		return true
+	}
+	a, ok := decodeJSON(req.Body)
+	if !ok {
+		return false
+	}
+	var b interface{}
+	if b, ok = decodeJSON(recorded.Body); !ok {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func decodeJSON(body string) (interface{}, bool) {
+	var v interface{}
+	decoder := jsoniter.ConfigCompatibleWithStandardLibrary.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return nil, false
+	}
+	return v, true
+}
+
+// MatchHeader matches the values of the named headers
+func MatchHeader(names ...string) Matcher {
+	return func(req, recorded *RecordedRequest) bool {
+		for _, name := range names {
+			if strings.Join(req.Header.Values(name), ",") != strings.Join(recorded.Header.Values(name), ",") {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+type CassetteOption struct {
+	Mode CassetteMode
+	// Client sends the requests to record
+	Client Client
+	// Matchers all must match, default are method, url and body
+	Matchers []Matcher
+	// Redactor scrubs secrets before the interactions are saved, MaxBody is ignored
+	Redactor Redactor
+}
+
+// NewCassette returns a Client recording interactions to the json file at path and replaying them.
+// Interactions are replayed in the recorded order, every one is used at most once.
+// Bodies are saved as text, so it is not suited for binary payloads.
+func NewCassette(path string, opts ...func(*CassetteOption)) (*Cassette, error) {
+	o := CassetteOption{
+		Mode:     ModeReplay,
+		Client:   NewStandardClient(),
+		Matchers: []Matcher{MatchMethod, MatchURL, MatchBody},
+		Redactor: DefaultRedactor(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.Redactor.MaxBody = 0
+	c := &Cassette{path: path, CassetteOption: o}
+	if o.Mode == ModeRecord {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if o.Mode == ModeReplayOrRecord && errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if err = jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &c.interactions); err != nil {
+		return nil, fmt.Errorf("decode cassette %s,%w", path, err)
+	}
+	c.used = make([]bool, len(c.interactions))
+	return c, nil
+}
+
+type Cassette struct {
+	path string
+	CassetteOption
+
+	mutex        sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+func (c *Cassette) Do(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := c.scrubRequest(req, body)
+	if c.Mode != ModeRecord {
+		if interaction := c.find(recorded); interaction != nil {
+			return interaction.Response.toResponse(req), nil
+		}
+		if c.Mode == ModeReplay {
+			return nil, fmt.Errorf("%w,%s %s", ErrInteractionNotFound, recorded.Method, recorded.URL)
+		}
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	var respBody []byte
+	respBody, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	if err = c.record(&Interaction{
+		Request: *recorded,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     c.Redactor.Header(resp.Header),
+			Body:       c.Redactor.Body(respBody),
+		},
+	}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Interactions returns the interactions loaded or recorded
+func (c *Cassette) Interactions() []*Interaction {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]*Interaction(nil), c.interactions...)
+}
+
+func (c *Cassette) find(req *RecordedRequest) *Interaction {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, interaction := range c.interactions {
+		if c.used[i] || !c.match(req, &interaction.Request) {
+			continue
+		}
+		c.used[i] = true
+		return interaction
+	}
+	return nil
+}
+
+func (c *Cassette) match(req, recorded *RecordedRequest) bool {
+	for _, matcher := range c.Matchers {
+		if !matcher(req, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+// record appends the interaction and saves the whole cassette
+func (c *Cassette) record(interaction *Interaction) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.interactions = append(c.interactions, interaction)
+	// 新录制的交互已被本次请求使用
+	c.used = append(c.used, true)
+	data, err := jsoniter.ConfigCompatibleWithStandardLibrary.MarshalIndent(c.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644) // nolint:gosec
+}
+
+// scrubRequest converts req to the recorded form with secrets masked,
+// incoming requests are scrubbed the same way so they match the saved ones
+func (c *Cassette) scrubRequest(req *http.Request, body []byte) *RecordedRequest {
+	return &RecordedRequest{
+		Method: req.Method,
+		URL:    c.Redactor.URL(req.URL).String(),
+		Header: c.Redactor.Header(req.Header),
+		Body:   c.Redactor.Body(body),
+	}
+}
+
+func (r *RecordedResponse) toResponse(req *http.Request) *http.Response {
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// readBody reads the body of req and makes it readable again
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
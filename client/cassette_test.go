@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCassette(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=abc")
+		_, _ = w.Write([]byte(`{"token":"t1","echo":` + string(body) + `}`))
+	}))
+	path := filepath.Join(t.TempDir(), "fixtures", "login.json")
+	header := http.Header{"Authorization": []string{"Bearer secret"}, "X-Tenant": []string{"a"}}
+	login := func(c Client, password string) (*http.Response, error) {
+		req, err := NewRequest(context.Background(), http.MethodPost, server.URL+"/login?access_token=x",
+			[]byte(`{"user":"u","password":"`+password+`"}`), header)
+		require.NoError(t, err)
+		return c.Do(req)
+	}
+
+	recorder, err := NewCassette(path, func(o *CassetteOption) { o.Mode = ModeRecord })
+	require.NoError(t, err)
+	resp, err := login(recorder, "p1")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `"token":"t1"`)
+	server.Close()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{"Bearer secret", "p1", "access_token=x", "t1", "session=abc"} {
+		require.False(t, strings.Contains(string(data), secret), secret)
+	}
+
+	player, err := NewCassette(path, func(o *CassetteOption) {
+		o.Matchers = append(o.Matchers, MatchHeader("X-Tenant"))
+	})
+	require.NoError(t, err)
+	// 密码不同但脱敏后一致
+	resp, err = login(player, "p2")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `"token":"******"`)
+
+	_, err = login(player, "p1")
+	require.True(t, errors.Is(err, ErrInteractionNotFound))
+
+	player, err = NewCassette(path, func(o *CassetteOption) {
+		o.Matchers = append(o.Matchers, MatchHeader("X-Tenant"))
+	})
+	require.NoError(t, err)
+	header.Set("X-Tenant", "b")
+	_, err = login(player, "p1")
+	require.True(t, errors.Is(err, ErrInteractionNotFound))
+
+	_, err = NewCassette(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
+
+func TestMatchBody(t *testing.T) {
+	for _, tt := range []struct {
+		a, b  string
+		match bool
+	}{
+		{a: `{"a":1,"b":[1,2]}`, b: "{ \"b\": [1, 2],\n \"a\": 1 }\n", match: true},
+		{a: `{"a":1}`, b: `{"a":1.0}`},
+		{a: `{"a":1}`, b: `{"a":1} {}`},
+		{a: "a=1&b=2", b: "a=1&b=2", match: true},
+		{a: "a=1&b=2", b: "b=2&a=1"},
+	} {
+		require.Equal(t, tt.match, MatchBody(&RecordedRequest{Body: tt.a}, &RecordedRequest{Body: tt.b}), tt.b)
+	}
+}
@@ -18,7 +18,12 @@ type Client interface {
 type Option func(*option)
 
 type option struct {
-	t *http.Transport
+	t        *http.Transport
+	timeout  time.Duration
+	dialer   *net.Dialer
+	unix     string
+	dnsCache time.Duration
+	lookup   ipResolver // DNSCache 使用的解析器，为空时使用 dialer.Resolver
 }
 
 // TLSConfig config tls
@@ -26,28 +31,29 @@ func TLSConfig(cfg *tls.Config) Option {
 	return func(o *option) { o.t.TLSClientConfig = cfg }
 }
 
-// Timeout config timeout
+// Timeout config the total deadline of a request including reading the response body, 0 means no limit
 func Timeout(t time.Duration) Option {
-	return func(o *option) { o.t.ResponseHeaderTimeout = t }
+	return func(o *option) { o.timeout = t }
 }
 
 func NewStandardClient(opts ...Option) *standardClient {
 	o := &option{
 		t: &http.Transport{
-			MaxIdleConnsPerHost: 100,
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
+			MaxIdleConnsPerHost:   100,
 			TLSHandshakeTimeout:   10 * time.Second,
 			ResponseHeaderTimeout: 300 * time.Second,
 			ForceAttemptHTTP2:     true,
 		},
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
 	}
 	for _, opt := range opts {
 		opt(o)
 	}
-	return &standardClient{client: &http.Client{Transport: o.t}}
+	o.t.DialContext = o.dialContext()
+	return &standardClient{client: &http.Client{Transport: o.t, Timeout: o.timeout}}
 }
 
 type standardClient struct {
@@ -57,3 +63,8 @@ type standardClient struct {
 func (s *standardClient) Do(req *http.Request) (*http.Response, error) {
 	return s.client.Do(req)
 }
+
+// CloseIdleConnections closes idle connections of the transport
+func (s *standardClient) CloseIdleConnections() {
+	s.client.CloseIdleConnections()
+}
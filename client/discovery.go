@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/crochee/lirity/logger"
+	"github.com/crochee/lirity/registry"
+)
+
+// ErrNoEndpoint the service has no endpoint to pick
+var ErrNoEndpoint = errors.New("no endpoint")
+
+// noEndpointScheme is the scheme of URL when there is no endpoint,
+// NewRequest and Middleware fail the request with ErrNoEndpoint
+const noEndpointScheme = "no-endpoint"
+
+type DiscoveryOption struct {
+	Balancer Balancer
+	// MaxFails consecutive failures eject the endpoint for EjectDuration, 0 means never eject
+	MaxFails      int
+	EjectDuration time.Duration
+	// IsFailure default treats error and 5xx as failure
+	IsFailure func(resp *http.Response, err error) bool
+	// RetryInterval wait before watching again when the watcher fails
+	RetryInterval time.Duration
+}
+
+// NewDiscoveryHandler returns URLHandler resolving serviceName through discovery,
+// it keeps watching the service until ctx is done or Close is called
+func NewDiscoveryHandler(ctx context.Context, discovery registry.Discovery, serviceName string,
+	opts ...func(*DiscoveryOption)) (*discoveryHandler, error) {
+	o := DiscoveryOption{
+		Balancer:      NewRoundRobin(),
+		MaxFails:      5,
+		EjectDuration: 30 * time.Second,
+		IsFailure: func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= http.StatusInternalServerError
+		},
+		RetryInterval: time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	newCtx, cancel := context.WithCancel(ctx)
+	d := &discoveryHandler{
+		DiscoveryOption: o,
+		discovery:       discovery,
+		serviceName:     serviceName,
+		ctx:             newCtx,
+		cancel:          cancel,
+		endpoints:       make(map[string]*Endpoint),
+	}
+	if err := d.refresh(newCtx); err != nil {
+		cancel()
+		return nil, err
+	}
+	go d.watch()
+	return d, nil
+}
+
+type discoveryHandler struct {
+	DiscoveryOption
+	DefaultIP
+	discovery   registry.Discovery
+	serviceName string
+	ctx         context.Context
+	cancel      context.CancelFunc
+
+	mutex     sync.RWMutex
+	endpoints map[string]*Endpoint
+	list      []*Endpoint
+}
+
+func (d *discoveryHandler) URLWithQuery(ctx context.Context, path string, value url.Values) string {
+	if len(value) == 0 {
+		return d.URL(ctx, path)
+	}
+	return d.URL(ctx, path) + "?" + value.Encode()
+}
+
+// URL returns no-endpoint:// URL when there is no endpoint, NewRequest and Middleware fail it with ErrNoEndpoint
+func (d *discoveryHandler) URL(ctx context.Context, path string) string {
+	u, err := d.Endpoint(ctx, path)
+	if err != nil {
+		logger.From(ctx).Error(err.Error())
+		return noEndpointScheme + "://" + path
+	}
+	return u
+}
+
+// Endpoint returns the url of path on a picked endpoint, the error is ErrNoEndpoint when there is no endpoint
+func (d *discoveryHandler) Endpoint(ctx context.Context, path string) (string, error) {
+	e := d.Pick(ctx)
+	if e == nil {
+		return "", d.noEndpoint()
+	}
+	return e.Address + path, nil
+}
+
+func (d *discoveryHandler) noEndpoint() error {
+	return fmt.Errorf("service %s has %w", d.serviceName, ErrNoEndpoint)
+}
+
+// Pick returns a healthy endpoint, all endpoints are candidates when all of them are ejected
+func (d *discoveryHandler) Pick(ctx context.Context) *Endpoint {
+	d.mutex.RLock()
+	list := d.list
+	d.mutex.RUnlock()
+	if len(list) == 0 {
+		return nil
+	}
+	now := time.Now().UnixNano()
+	healthy := make([]*Endpoint, 0, len(list))
+	for _, e := range list {
+		if atomic.LoadInt64(&e.ejectedUntil) <= now {
+			healthy = append(healthy, e)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = list
+	}
+	return d.Balancer.Pick(ctx, healthy)
+}
+
+// Middleware counts outstanding requests and ejects failing endpoints,
+// the Client using this handler should be wrapped by it
+func (d *discoveryHandler) Middleware() Middleware {
+	return func(next Client) Client {
+		return ClientFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Scheme == noEndpointScheme {
+				return nil, d.noEndpoint()
+			}
+			d.mutex.RLock()
+			e, ok := d.endpoints[req.URL.Scheme+"://"+req.URL.Host]
+			d.mutex.RUnlock()
+			if !ok {
+				return next.Do(req)
+			}
+			atomic.AddInt64(&e.outstanding, 1)
+			resp, err := next.Do(req)
+			atomic.AddInt64(&e.outstanding, -1)
+			d.report(e, d.IsFailure(resp, err))
+			return resp, err
+		})
+	}
+}
+
+func (d *discoveryHandler) report(e *Endpoint, failure bool) {
+	if !failure {
+		atomic.StoreInt32(&e.fails, 0)
+		return
+	}
+	if d.MaxFails > 0 && int(atomic.AddInt32(&e.fails, 1)) >= d.MaxFails {
+		atomic.StoreInt32(&e.fails, 0)
+		atomic.StoreInt64(&e.ejectedUntil, time.Now().Add(d.EjectDuration).UnixNano())
+	}
+}
+
+// Close stop watching
+func (d *discoveryHandler) Close() error {
+	d.cancel()
+	return nil
+}
+
+func (d *discoveryHandler) watch() {
+	for {
+		watcher, err := d.discovery.Watch(d.ctx, d.serviceName)
+		if err == nil {
+			err = d.next(watcher)
+			_ = watcher.Stop()
+		}
+		if d.ctx.Err() != nil {
+			return
+		}
+		logger.From(d.ctx).Sugar().Errorf("watch service %s failed,%v", d.serviceName, err)
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(d.RetryInterval):
+		}
+		// 重新建立watch前同步一次，避免漏掉期间的变化
+		if err = d.refresh(d.ctx); err != nil {
+			logger.From(d.ctx).Sugar().Errorf("get service %s failed,%v", d.serviceName, err)
+		}
+	}
+}
+
+func (d *discoveryHandler) next(watcher registry.Watcher) error {
+	for {
+		if _, err := watcher.Next(); err != nil {
+			return err
+		}
+		// Next may return only the changed instances, so read the full list again
+		if err := d.refresh(d.ctx); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *discoveryHandler) refresh(ctx context.Context) error {
+	services, err := d.discovery.GetService(ctx, d.serviceName)
+	if err != nil {
+		return err
+	}
+	d.update(services)
+	return nil
+}
+
+func (d *discoveryHandler) update(services []*registry.ServiceInstance) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	endpoints := make(map[string]*Endpoint, len(d.endpoints))
+	list := make([]*Endpoint, 0, len(d.list))
+	for _, service := range services {
+		weight, _ := strconv.Atoi(service.Metadata["weight"])
+		for _, raw := range service.Endpoints {
+			address, err := httpAddress(raw)
+			if err != nil {
+				continue
+			}
+			if _, ok := endpoints[address]; ok {
+				continue
+			}
+			// 保留已有endpoint的状态
+			e, ok := d.endpoints[address]
+			if !ok || e.Weight != weight {
+				e = &Endpoint{Address: address, Weight: weight}
+			}
+			endpoints[address] = e
+			list = append(list, e)
+		}
+	}
+	d.endpoints = endpoints
+	d.list = list
+}
+
+// httpAddress parse http://127.0.0.1:8000?isSecure=false to scheme://host
+func httpAddress(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	scheme := u.Scheme
+	switch scheme {
+	case "http", "https":
+	default:
+		return "", errors.New("not http endpoint " + raw)
+	}
+	if secure, _ := strconv.ParseBool(u.Query().Get("isSecure")); secure {
+		scheme = "https"
+	}
+	return scheme + "://" + u.Host, nil
+}
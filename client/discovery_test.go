@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/crochee/lirity/registry"
+)
+
+type fakeDiscovery struct {
+	mutex    sync.Mutex
+	services []*registry.ServiceInstance
+	changes  chan struct{}
+}
+
+func (f *fakeDiscovery) set(services ...*registry.ServiceInstance) {
+	f.mutex.Lock()
+	f.services = services
+	f.mutex.Unlock()
+	f.changes <- struct{}{}
+}
+
+func (f *fakeDiscovery) GetService(context.Context, string) ([]*registry.ServiceInstance, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.services, nil
+}
+
+func (f *fakeDiscovery) Watch(ctx context.Context, _ string) (registry.Watcher, error) {
+	return &fakeWatcher{ctx: ctx, changes: f.changes}, nil
+}
+
+type fakeWatcher struct {
+	ctx     context.Context
+	changes chan struct{}
+}
+
+func (f *fakeWatcher) Next() ([]*registry.ServiceInstance, error) {
+	select {
+	case <-f.changes:
+		return nil, nil
+	case <-f.ctx.Done():
+		return nil, f.ctx.Err()
+	}
+}
+
+func (f *fakeWatcher) Stop() error {
+	return nil
+}
+
+func TestDiscoveryHandler(t *testing.T) {
+	d := &fakeDiscovery{
+		services: []*registry.ServiceInstance{{
+			Name:      "api",
+			Endpoints: []string{"http://10.0.0.1:80", "grpc://10.0.0.1:90", "http://10.0.0.2:80?isSecure=true"},
+		}},
+		changes: make(chan struct{}),
+	}
+	h, err := NewDiscoveryHandler(context.Background(), d, "api", func(o *DiscoveryOption) {
+		o.MaxFails = 2
+	})
+	require.NoError(t, err)
+	defer h.Close()
+
+	ctx := context.Background()
+	require.Equal(t, "http://10.0.0.1:80/v1", h.URL(ctx, "/v1"))
+	require.Equal(t, "https://10.0.0.2:80/v1?a=b", h.URLWithQuery(ctx, "/v1", map[string][]string{"a": {"b"}}))
+
+	// eject 10.0.0.1 after 2 failures
+	c := Chain(ClientFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "10.0.0.1:80" {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}), h.Middleware())
+	for i := 0; i < 2; i++ {
+		req, err := NewRequest(ctx, http.MethodGet, "http://10.0.0.1:80/v1", nil, nil)
+		require.NoError(t, err)
+		_, err = c.Do(req)
+		require.Error(t, err)
+	}
+	for i := 0; i < 4; i++ {
+		require.Equal(t, "https://10.0.0.2:80/v1", h.URL(ctx, "/v1"))
+	}
+
+	d.set(&registry.ServiceInstance{Name: "api", Endpoints: []string{"http://10.0.0.3:80"}})
+	require.Eventually(t, func() bool {
+		return h.URL(ctx, "/v1") == "http://10.0.0.3:80/v1"
+	}, time.Second, 10*time.Millisecond)
+
+	d.set()
+	require.Eventually(t, func() bool {
+		_, err = h.Endpoint(ctx, "/v1")
+		return errors.Is(err, ErrNoEndpoint)
+	}, time.Second, 10*time.Millisecond)
+	// 没有经过Middleware时由NewRequest返回
+	_, err = NewRequest(ctx, http.MethodGet, h.URL(ctx, "/v1"), nil, nil)
+	require.True(t, errors.Is(err, ErrNoEndpoint))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL(ctx, "/v1"), nil)
+	require.NoError(t, err)
+	_, err = c.Do(req)
+	require.True(t, errors.Is(err, ErrNoEndpoint))
+}
+
+func TestBalancer(t *testing.T) {
+	a := &Endpoint{Address: "a", Weight: 1}
+	b := &Endpoint{Address: "b", Weight: 3}
+	endpoints := []*Endpoint{a, b}
+	ctx := context.Background()
+
+	rr := NewRoundRobin()
+	require.Equal(t, []*Endpoint{a, b, a},
+		[]*Endpoint{rr.Pick(ctx, endpoints), rr.Pick(ctx, endpoints), rr.Pick(ctx, endpoints)})
+
+	counts := map[string]int{}
+	wr := NewWeightedRandom()
+	for i := 0; i < 4000; i++ {
+		counts[wr.Pick(ctx, endpoints).Address]++
+	}
+	require.InDelta(t, 3000, counts["b"], 300)
+
+	a.outstanding = 2
+	require.Equal(t, b, NewLeastOutstanding().Pick(ctx, endpoints))
+
+	ch := NewConsistentHash(0)
+	picked := ch.Pick(WithHashKey(ctx, "user-1"), endpoints)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, picked, ch.Pick(WithHashKey(ctx, "user-1"), endpoints))
+	}
+}
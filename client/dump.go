@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"moul.io/http2curl"
+
+	"github.com/crochee/lirity/logger"
+)
+
+type DumpOption struct {
+	// Enable dumps every request at Level, otherwise only requests whose context is set by WithDump
+	Enable bool
+	Level  zapcore.Level
+	// Redactor masks headers, query parameters and json fields named by Redactor.Fields and truncates bodies
+	Redactor Redactor
+	// Response dumps status, latency and body by Dump middleware
+	Response bool
+}
+
+var dumpOption atomic.Value
+
+func init() {
+	dumpOption.Store(DumpOption{
+		Enable:   true,
+		Level:    zapcore.DebugLevel,
+		Redactor: DefaultRedactor(),
+	})
+}
+
+// SetDump changes how NewRequest and Dump log requests and responses
+func SetDump(opts ...func(*DumpOption)) {
+	o := dumpOption.Load().(DumpOption)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	dumpOption.Store(o)
+}
+
+type dumpKey struct{}
+
+// WithDump turns dumping of the requests created with ctx on or off,
+// requests turned on are logged at info level so that they show up in production
+func WithDump(ctx context.Context, enable bool) context.Context {
+	return context.WithValue(ctx, dumpKey{}, enable)
+}
+
+// dumpConfig returns the option, the level to log at and whether to log
+func dumpConfig(ctx context.Context) (DumpOption, zapcore.Level, bool) {
+	o := dumpOption.Load().(DumpOption)
+	if enable, ok := ctx.Value(dumpKey{}).(bool); ok {
+		level := zapcore.InfoLevel
+		if o.Level > level {
+			level = o.Level
+		}
+		return o, level, enable
+	}
+	return o, o.Level, o.Enable
+}
+
+// dumpCurl logs req as curl command, body is logged instead of reading req.Body
+func dumpCurl(ctx context.Context, req *http.Request, body []byte) {
+	o, level, ok := dumpConfig(ctx)
+	if !ok {
+		return
+	}
+	log := logger.From(ctx)
+	ce := log.Check(level, "")
+	if ce == nil {
+		return
+	}
+	clone := req.Clone(ctx)
+	clone.Header = o.Redactor.Header(req.Header)
+	clone.URL = o.Redactor.URL(req.URL)
+	clone.Body = nil
+	if body != nil {
+		clone.Body = io.NopCloser(bytes.NewReader([]byte(o.Redactor.Body(body))))
+	}
+	curl, err := http2curl.GetCurlCommand(clone)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	ce.Message = curl.String()
+	ce.Write()
+}
+
+// Dump logs status, latency and body of responses when DumpOption.Response is set
+func Dump() Middleware {
+	return func(next Client) Client {
+		return ClientFunc(func(req *http.Request) (*http.Response, error) {
+			o, level, ok := dumpConfig(req.Context())
+			if !ok || !o.Response {
+				return next.Do(req)
+			}
+			start := time.Now()
+			resp, err := next.Do(req)
+			fields := []zap.Field{
+				zap.String("method", req.Method),
+				zap.String("url", o.Redactor.URL(req.URL).String()),
+				zap.Duration("latency", time.Since(start)),
+			}
+			log := logger.From(req.Context())
+			if err != nil {
+				log.Error(err.Error(), fields...)
+				return nil, err
+			}
+			if ce := log.Check(level, "http response"); ce != nil {
+				fields = append(fields,
+					zap.Int("status", resp.StatusCode),
+					zap.Any("header", o.Redactor.Header(resp.Header)))
+				if body, ok := peekResponse(resp, o.Redactor.MaxBody); ok {
+					fields = append(fields,
+						zap.String("body", o.Redactor.PartialBody(body, responseSize(resp, body, o.Redactor.MaxBody))))
+				}
+				ce.Write(fields...)
+			}
+			return resp, nil
+		})
+	}
+}
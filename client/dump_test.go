@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/crochee/lirity/logger"
+)
+
+func TestDump(t *testing.T) {
+	defer SetDump(func(o *DumpOption) {
+		o.Enable = true
+		o.Response = false
+		o.Redactor = DefaultRedactor()
+	})
+	SetDump(func(o *DumpOption) {
+		o.Enable = false
+		o.Response = true
+		o.Redactor.MaxBody = 20
+	})
+	core, logs := observer.New(zapcore.InfoLevel)
+	ctx := logger.With(context.Background(), zap.New(core))
+
+	_, err := NewRequest(ctx, http.MethodGet, "http://a.com", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, logs.Len())
+
+	ctx = WithDump(ctx, true)
+	req, err := NewRequest(ctx, http.MethodPost, "http://a.com/v1?access_token=abc&page=1",
+		[]byte(`{"password":"p","name":"0123456789"}`), http.Header{"Authorization": []string{"Bearer x"}})
+	require.NoError(t, err)
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"password":"p","name":"0123456789"}`, string(body))
+	require.Equal(t, 1, logs.Len())
+	require.Equal(t, `curl -X 'POST' -d '{"name":"0123456789"...(21 bytes truncated)' `+
+		`-H 'Authorization: ******' 'http://a.com/v1?access_token=%2A%2A%2A%2A%2A%2A&page=1'`,
+		logs.All()[0].Message)
+
+	c := Chain(ClientFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"token":"t"}`)),
+		}, nil
+	}), Dump())
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"token":"t"}`, string(body))
+	entries := logs.FilterMessage("http response").All()
+	require.Len(t, entries, 1)
+	require.Equal(t, `{"token":"******"}`, entries[0].ContextMap()["body"])
+}
+
+func TestDumpTruncated(t *testing.T) {
+	defer SetDump(func(o *DumpOption) {
+		o.Response = false
+		o.Redactor = DefaultRedactor()
+	})
+	SetDump(func(o *DumpOption) {
+		o.Response = true
+		o.Redactor.MaxBody = 32
+	})
+	core, logs := observer.New(zapcore.InfoLevel)
+	ctx := WithDump(logger.With(context.Background(), zap.New(core)), true)
+	content := `{"refresh_token":"SECRET","data":"` + strings.Repeat("x", 128) + `"}`
+	c := Chain(ClientFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			ContentLength: int64(len(content)),
+			Body:          io.NopCloser(strings.NewReader(content)),
+		}, nil
+	}), Dump())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://a.com", nil)
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, content, string(body))
+	entries := logs.FilterMessage("http response").All()
+	require.Len(t, entries, 1)
+	require.Equal(t, `{"refresh_token":"******","data"...(132 bytes truncated)`, entries[0].ContextMap()["body"])
+	require.NotContains(t, entries[0].ContextMap()["body"], "SECRET")
+}
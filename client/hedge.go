@@ -0,0 +1,239 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+type HedgeOption struct {
+	// URLHandler picks the endpoint of every hedged request, nil means the same url as the original request
+	URLHandler URLHandler
+	// Delay before sending a hedged request, used until enough latencies are observed when Percentile is set
+	Delay time.Duration
+	// Percentile of observed latencies in (0,1) used as delay, 0 means fixed Delay
+	Percentile float64
+	// MaxHedges hedged requests at most for a request
+	MaxHedges int
+	// Budget every request deposits and every hedged request withdraws, nil means unlimited
+	Budget *RetryBudget
+	// Hedgeable decide whether the request can be hedged, default GET and HEAD without body
+	Hedgeable func(req *http.Request) bool
+	// IsFailure responses are not taken unless all requests fail
+	IsFailure func(resp *http.Response, err error) bool
+}
+
+// NewHedgeClient wraps c to send duplicate requests to other endpoints when the response is slow,
+// the first successful response is returned and the others are cancelled.
+// The default budget allows 10% extra requests so hedging never doubles the load.
+func NewHedgeClient(c Client, opts ...func(*HedgeOption)) Client {
+	o := HedgeOption{
+		Delay:     50 * time.Millisecond,
+		MaxHedges: 1,
+		Budget:    NewRetryBudget(0.1, 10),
+		Hedgeable: func(req *http.Request) bool {
+			return (req.Method == http.MethodGet || req.Method == http.MethodHead) &&
+				(req.Body == nil || req.Body == http.NoBody)
+		},
+		IsFailure: func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= http.StatusInternalServerError
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &hedgeClient{client: c, HedgeOption: o, latency: newLatencyWindow(256)}
+}
+
+type hedgeClient struct {
+	client Client
+	HedgeOption
+	latency *latencyWindow
+}
+
+type hedgeResult struct {
+	index   int
+	resp    *http.Response
+	err     error
+	latency time.Duration
+}
+
+func (h *hedgeClient) Do(req *http.Request) (*http.Response, error) {
+	if !h.Hedgeable(req) {
+		return h.client.Do(req)
+	}
+	if h.Budget != nil {
+		h.Budget.deposit()
+	}
+	ctx := req.Context()
+	results := make(chan hedgeResult, h.MaxHedges+1)
+	var cancels []context.CancelFunc
+	send := func(r *http.Request) {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			start := time.Now()
+			resp, err := h.client.Do(r.WithContext(attemptCtx))
+			results <- hedgeResult{index: index, resp: resp, err: err, latency: time.Since(start)}
+		}()
+	}
+	// take cancels the others and returns the response whose context is cancelled on close
+	take := func(result hedgeResult, outstanding int) (*http.Response, error) {
+		for i, cancel := range cancels {
+			if i != result.index {
+				cancel()
+			}
+		}
+		drain(results, outstanding)
+		if result.resp == nil {
+			cancels[result.index]()
+			return nil, result.err
+		}
+		result.resp.Body = &cancelBody{ReadCloser: result.resp.Body, cancel: cancels[result.index]}
+		return result.resp, nil
+	}
+
+	send(req)
+	outstanding, hedges := 1, 0
+	timer := time.NewTimer(h.delay())
+	defer timer.Stop()
+	var last hedgeResult
+	for {
+		select {
+		case result := <-results:
+			outstanding--
+			if !h.IsFailure(result.resp, result.err) {
+				// 对冲请求胜出时记录它自身的耗时
+				h.latency.add(result.latency)
+				closeResult(last)
+				return take(result, outstanding)
+			}
+			closeResult(last)
+			last = result
+			if outstanding > 0 {
+				continue
+			}
+			// 全部失败时立即对冲而不是等待
+			if !h.hedge(req, &hedges, send) {
+				return take(result, 0)
+			}
+			outstanding++
+		case <-timer.C:
+			if h.hedge(req, &hedges, send) {
+				outstanding++
+				timer.Reset(h.delay())
+			}
+		case <-ctx.Done():
+			closeResult(last)
+			for _, cancel := range cancels {
+				cancel()
+			}
+			drain(results, outstanding)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// drain closes the responses of the cancelled requests in background
+func drain(results <-chan hedgeResult, outstanding int) {
+	if outstanding == 0 {
+		return
+	}
+	go func() {
+		for i := 0; i < outstanding; i++ {
+			closeResult(<-results)
+		}
+	}()
+}
+
+func closeResult(result hedgeResult) {
+	if result.resp != nil {
+		_ = result.resp.Body.Close()
+	}
+}
+
+// hedge sends another request if hedges and budget are left
+func (h *hedgeClient) hedge(req *http.Request, hedges *int, send func(*http.Request)) bool {
+	if *hedges >= h.MaxHedges {
+		return false
+	}
+	if h.Budget != nil && !h.Budget.withdraw() {
+		return false
+	}
+	*hedges++
+	send(h.hedgeRequest(req))
+	return true
+}
+
+func (h *hedgeClient) hedgeRequest(req *http.Request) *http.Request {
+	hedged := req.Clone(req.Context())
+	if h.URLHandler == nil {
+		return hedged
+	}
+	// Clone复制了URL，只替换endpoint，转义的路径和查询参数不变
+	u, err := url.Parse(h.URLHandler.URL(req.Context(), req.URL.EscapedPath()))
+	if err != nil || u.Scheme == noEndpointScheme {
+		return hedged
+	}
+	hedged.URL.Scheme = u.Scheme
+	hedged.URL.Host = u.Host
+	hedged.Host = ""
+	return hedged
+}
+
+func (h *hedgeClient) delay() time.Duration {
+	if h.Percentile > 0 {
+		if d, ok := h.latency.percentile(h.Percentile); ok {
+			return d
+		}
+	}
+	return h.Delay
+}
+
+// latencyWindow keeps the latest latencies of successful requests
+type latencyWindow struct {
+	mutex   sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+func newLatencyWindow(size int) *latencyWindow {
+	return &latencyWindow{samples: make([]time.Duration, size)}
+}
+
+func (l *latencyWindow) add(d time.Duration) {
+	l.mutex.Lock()
+	l.samples[l.next] = d
+	l.next++
+	if l.next == len(l.samples) {
+		l.next, l.full = 0, true
+	}
+	l.mutex.Unlock()
+}
+
+// percentile returns false until 10 samples are observed
+func (l *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	l.mutex.Lock()
+	n := l.next
+	if l.full {
+		n = len(l.samples)
+	}
+	if n < 10 {
+		l.mutex.Unlock()
+		return 0, false
+	}
+	sorted := make([]time.Duration, n)
+	copy(sorted, l.samples[:n])
+	l.mutex.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(p * float64(n))
+	if i >= n {
+		i = n - 1
+	}
+	return sorted[i], true
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type hostHandler struct {
+	DefaultIP
+	host string
+}
+
+func (h hostHandler) URL(ctx context.Context, path string) string {
+	return h.host + path
+}
+
+func TestHedgeClient(t *testing.T) {
+	var slowCancelled int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			atomic.AddInt32(&slowCancelled, 1)
+		case <-time.After(time.Second):
+			_, _ = w.Write([]byte("slow"))
+		}
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("fast" + r.URL.RequestURI()))
+	}))
+	defer fast.Close()
+
+	c := NewHedgeClient(NewStandardClient(), func(o *HedgeOption) {
+		o.URLHandler = hostHandler{host: fast.URL}
+		o.Delay = 20 * time.Millisecond
+		o.Budget = NewRetryBudget(0.1, 1)
+	})
+	do := func(method string) (string, time.Duration) {
+		req, err := NewRequest(context.Background(), method, slow.URL+"/v1/a%2Fb?a=1", nil, nil)
+		require.NoError(t, err)
+		start := time.Now()
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body), time.Since(start)
+	}
+	body, elapsed := do(http.MethodGet)
+	require.Equal(t, "fast/v1/a%2Fb?a=1", body)
+	require.Less(t, int64(elapsed), int64(500*time.Millisecond))
+	// 记录的是对冲请求的耗时，不包含等待的Delay
+	require.Less(t, int64(c.(*hedgeClient).latency.samples[0]), int64(20*time.Millisecond))
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&slowCancelled) == 1 },
+		time.Second, 10*time.Millisecond)
+
+	// 预算耗尽后不再对冲
+	body, _ = do(http.MethodGet)
+	require.Equal(t, "slow", body)
+}
+
+func TestHedgeClientFailure(t *testing.T) {
+	var calls int32
+	c := NewHedgeClient(ClientFunc(func(req *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}), func(o *HedgeOption) { o.Delay = time.Hour })
+	req, err := NewRequest(context.Background(), http.MethodGet, "http://a.com", nil, nil)
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	req, err = NewRequest(context.Background(), http.MethodPost, "http://a.com", []byte("{}"), nil)
+	require.NoError(t, err)
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestLatencyWindow(t *testing.T) {
+	l := newLatencyWindow(20)
+	_, ok := l.percentile(0.9)
+	require.False(t, ok)
+	for i := 1; i <= 30; i++ {
+		l.add(time.Duration(i) * time.Millisecond)
+	}
+	d, ok := l.percentile(0.9)
+	require.True(t, ok)
+	require.Equal(t, 29*time.Millisecond, d)
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limit of a host, zero value means unlimited
+type Limit struct {
+	// Rate requests per second with Burst, Burst < 1 is treated as 1
+	Rate  float64
+	Burst int
+	// MaxConcurrent requests in flight, a request is in flight until its body is closed
+	MaxConcurrent int
+}
+
+// LimitRule applies Limit to every host matching Host whose request path matches Path,
+// both are path.Match patterns and empty means any
+type LimitRule struct {
+	Host string
+	Path string
+	Limit
+}
+
+type LimitOption struct {
+	// Default applies to requests matching none of Rules
+	Default Limit
+	// Rules are matched in order
+	Rules []LimitRule
+}
+
+// NewLimitClient wraps c with per host token bucket rate limits and concurrency limits,
+// requests wait until they are allowed or their context is done
+func NewLimitClient(c Client, opts ...func(*LimitOption)) *limitClient {
+	var o LimitOption
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &limitClient{client: c, LimitOption: o, limiters: make(map[string]*limiter)}
+}
+
+type limitClient struct {
+	client Client
+	LimitOption
+
+	mutex    sync.Mutex
+	limiters map[string]*limiter
+}
+
+func (l *limitClient) Do(req *http.Request) (*http.Response, error) {
+	lim := l.get(req)
+	if lim == nil {
+		return l.client.Do(req)
+	}
+	release, err := lim.acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// QueueDepth returns the requests waiting per limiter, the key is host or host and path pattern of the rule
+func (l *limitClient) QueueDepth() map[string]int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	depth := make(map[string]int64, len(l.limiters))
+	for key, lim := range l.limiters {
+		depth[key] = atomic.LoadInt64(&lim.waiting)
+	}
+	return depth
+}
+
+func (l *limitClient) get(req *http.Request) *limiter {
+	host := req.URL.Host
+	key, limit := host, l.Default
+	for _, rule := range l.Rules {
+		if match(rule.Host, host) && match(rule.Path, req.URL.Path) {
+			key, limit = host+" "+rule.Path, rule.Limit
+			break
+		}
+	}
+	if limit.Rate <= 0 && limit.MaxConcurrent <= 0 {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	lim, ok := l.limiters[key]
+	if !ok {
+		lim = newLimiter(limit)
+		l.limiters[key] = lim
+	}
+	return lim
+}
+
+func match(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
+
+type limiter struct {
+	waiting int64
+
+	rate   float64
+	burst  float64
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+
+	sem chan struct{}
+}
+
+func newLimiter(limit Limit) *limiter {
+	lim := &limiter{rate: limit.Rate, burst: float64(limit.Burst), last: time.Now()}
+	if lim.burst < 1 {
+		lim.burst = 1
+	}
+	lim.tokens = lim.burst
+	if limit.MaxConcurrent > 0 {
+		lim.sem = make(chan struct{}, limit.MaxConcurrent)
+	}
+	return lim
+}
+
+// acquire waits for a token and a concurrency slot, the returned func releases the slot
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	atomic.AddInt64(&l.waiting, 1)
+	defer atomic.AddInt64(&l.waiting, -1)
+	if err := l.wait(ctx); err != nil {
+		return nil, err
+	}
+	if l.sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-l.sem }) }, nil
+}
+
+// wait reserves a token and sleeps until it is available
+func (l *limiter) wait(ctx context.Context) error {
+	if l.rate <= 0 {
+		return nil
+	}
+	l.mutex.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mutex.Unlock()
+	if err := sleep(ctx, delay); err != nil {
+		// 归还预留的令牌
+		l.mutex.Lock()
+		l.tokens++
+		l.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *releaseBody) Close() error {
+	err := r.ReadCloser.Close()
+	r.release()
+	return err
+}
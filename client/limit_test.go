@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitClient(t *testing.T) {
+	ok := ClientFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	c := NewLimitClient(ok, func(o *LimitOption) {
+		o.Rules = []LimitRule{{Host: "*.a.com", Path: "/v1/*", Limit: Limit{Rate: 20, Burst: 1}}}
+		o.Default = Limit{MaxConcurrent: 1}
+	})
+	do := func(ctx context.Context, uri string) (*http.Response, error) {
+		req, err := NewRequest(ctx, http.MethodGet, uri, nil, nil)
+		require.NoError(t, err)
+		return c.Do(req)
+	}
+
+	// rate 20/s burst 1: 5 requests take about 200ms
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		resp, err := do(context.Background(), "http://x.a.com/v1/users")
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	require.True(t, time.Since(start) >= 190*time.Millisecond, time.Since(start))
+
+	// concurrency 1: the second request waits until the first body is closed
+	first, err := do(context.Background(), "http://b.com/")
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = do(ctx, "http://b.com/")
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		resp, err := do(context.Background(), "http://b.com/")
+		require.NoError(t, err)
+		resp.Body.Close()
+	}()
+	require.Eventually(t, func() bool {
+		return c.QueueDepth()["b.com"] == 1
+	}, time.Second, time.Millisecond)
+	first.Body.Close()
+	wg.Wait()
+	require.Equal(t, int64(0), c.QueueDepth()["b.com"])
+}
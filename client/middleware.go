@@ -0,0 +1,189 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/crochee/lirity/id"
+	"github.com/crochee/lirity/logger"
+)
+
+// ClientFunc is an adapter to allow the use of ordinary functions as Client
+type ClientFunc func(req *http.Request) (*http.Response, error)
+
+func (f ClientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware decorates a Client
+type Middleware func(next Client) Client
+
+// Chain wraps c with middlewares, middlewares[0] is the outermost one,
+// so it sees the request first and the response last
+func Chain(c Client, middlewares ...Middleware) Client {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		c = middlewares[i](c)
+	}
+	return c
+}
+
+// RequestIDHeader default header of RequestID
+const RequestIDHeader = "X-Request-Id"
+
+// RequestID set a uuid to header when the request has none, header default is X-Request-Id
+func RequestID(header string) Middleware {
+	if header == "" {
+		header = RequestIDHeader
+	}
+	return func(next Client) Client {
+		return ClientFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(header) == "" {
+				req = req.Clone(req.Context())
+				req.Header.Set(header, id.UV4())
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// CallTimeout bounds each call including reading the response body
+func CallTimeout(d time.Duration) Middleware {
+	return func(next Client) Client {
+		return ClientFunc(func(req *http.Request) (*http.Response, error) {
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			resp, err := next.Do(req.WithContext(ctx))
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		})
+	}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelBody) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+type LogOption struct {
+	Redactor Redactor
+	// LogBody log request and response body
+	LogBody bool
+}
+
+// Logging logs request and response by logger.From(ctx)
+func Logging(opts ...func(*LogOption)) Middleware {
+	o := LogOption{
+		Redactor: DefaultRedactor(),
+		LogBody:  true,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(next Client) Client {
+		return ClientFunc(func(req *http.Request) (*http.Response, error) {
+			log := logger.From(req.Context())
+			fields := []zap.Field{
+				zap.String("method", req.Method),
+				zap.String("url", o.Redactor.URL(req.URL).String()),
+				zap.Any("request_header", o.Redactor.Header(req.Header)),
+			}
+			if o.LogBody {
+				fields = append(fields, zap.String("request_body", o.Redactor.Body(peekRequest(req))))
+			}
+			start := time.Now()
+			resp, err := next.Do(req)
+			fields = append(fields, zap.Duration("latency", time.Since(start)))
+			if err != nil {
+				log.Error(err.Error(), fields...)
+				return nil, err
+			}
+			fields = append(fields, zap.Int("status", resp.StatusCode),
+				zap.Any("response_header", o.Redactor.Header(resp.Header)))
+			if o.LogBody {
+				if body, ok := peekResponse(resp, o.Redactor.MaxBody); ok {
+					fields = append(fields, zap.String("response_body",
+						o.Redactor.PartialBody(body, responseSize(resp, body, o.Redactor.MaxBody))))
+				}
+			}
+			log.Info("http call", fields...)
+			return resp, nil
+		})
+	}
+}
+
+// peekRequest reads body by GetBody so that req.Body is untouched
+func peekRequest(req *http.Request) []byte {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	return data
+}
+
+// maxPeek bounds the peeked response body when Redactor.MaxBody is not limited
+const maxPeek = 1 << 20
+
+// peekResponse reads at most limit+1 bytes and puts them back to resp.Body, limit <= 0 means maxPeek.
+// Streams and bodies of unknown length are not read since reading them blocks until the stream sends enough,
+// ok is false then
+func peekResponse(resp *http.Response, limit int) (data []byte, ok bool) {
+	if resp.Body == nil || resp.ContentLength < 0 || isStream(resp.Header.Get("Content-Type")) {
+		return nil, false
+	}
+	if limit <= 0 {
+		limit = maxPeek
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// isStream reports whether the media type is sent as a stream, such as sse and ndjson
+func isStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "text/event-stream", "application/x-ndjson", "application/stream+json", "application/json-seq":
+		return true
+	}
+	return false
+}
+
+// responseSize is the size of the body whose first part is peeked, -1 means unknown
+func responseSize(resp *http.Response, peeked []byte, limit int) int64 {
+	if limit <= 0 {
+		limit = maxPeek
+	}
+	if len(peeked) > limit {
+		return resp.ContentLength
+	}
+	return int64(len(peeked))
+}
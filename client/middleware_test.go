@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/crochee/lirity/logger"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Client) Client {
+			return ClientFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+" before")
+				resp, err := next.Do(req)
+				order = append(order, name+" after")
+				return resp, err
+			})
+		}
+	}
+	c := Chain(ClientFunc(func(req *http.Request) (*http.Response, error) {
+		order = append(order, "client")
+		require.NotEmpty(t, req.Header.Get(RequestIDHeader))
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}), trace("a"), RequestID(""), trace("b"))
+	req, err := NewRequest(context.Background(), http.MethodGet, "http://a.com", nil, nil)
+	require.NoError(t, err)
+	_, err = c.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a before", "b before", "client", "b after", "a after"}, order)
+}
+
+func TestLogging(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	ctx := logger.With(context.Background(), zap.New(core))
+	c := Chain(ClientFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`{"access_token":"abc","expires_in":3600}`)),
+		}, nil
+	}), Logging())
+	req, err := NewRequest(ctx, http.MethodPost, "http://u:p@a.com/login?token=abc", []byte(`{"user":"u","password":"p"}`),
+		http.Header{"Authorization": []string{"Bearer x"}})
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"access_token":"abc","expires_in":3600}`, string(body))
+
+	entries := logs.FilterMessage("http call").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	require.Equal(t, "http://u@a.com/login?token=%2A%2A%2A%2A%2A%2A", fields["url"])
+	require.Equal(t, `{"password":"******","user":"u"}`, fields["request_body"])
+	require.Equal(t, `{"access_token":"******","expires_in":3600}`, fields["response_body"])
+	require.Equal(t, http.Header{"Authorization": []string{"******"}}, fields["request_header"])
+}
+
+func TestLoggingTruncated(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	ctx := logger.With(context.Background(), zap.New(core))
+	content := `{"access_token":"SECRET","pad":"` + strings.Repeat("x", 64) + `"}`
+	var length int64
+	c := Chain(ClientFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{},
+			ContentLength: length,
+			Body:          io.NopCloser(strings.NewReader(content)),
+		}, nil
+	}), Logging(func(o *LogOption) {
+		o.Redactor.MaxBody = 40
+	}))
+	for _, tt := range []struct {
+		length   int64
+		expected interface{}
+	}{
+		{length: int64(len(content)), expected: `{"access_token":"******","pad":"xxxxxxxx...(58 bytes truncated)`},
+		// 长度未知时不读取body
+		{length: -1, expected: nil},
+	} {
+		length = tt.length
+		req, err := NewRequest(ctx, http.MethodGet, "http://a.com/login", nil, nil)
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, content, string(body))
+		entries := logs.TakeAll()
+		require.Equal(t, tt.expected, entries[len(entries)-1].ContextMap()["response_body"])
+	}
+
+	r := DefaultRedactor()
+	r.MaxBody = 16
+	require.Equal(t, `{"token":"******...(2 bytes truncated)`, r.Body([]byte(`{"token":"abcdefghijklmnop"}`)))
+	require.Equal(t, `[{"a":1,"secret":"******"},{"b":"\"secret\":x"}]...(truncated)`,
+		DefaultRedactor().PartialBody([]byte(`[{"a":1,"secret":{"k":[1,"}"]}},{"b":"\"secret\":x"}]`), -1))
+}
+
+func TestLoggingStream(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	ctx := logger.With(context.Background(), zap.New(core))
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	c := Chain(ClientFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Type": []string{"text/event-stream; charset=utf-8"}},
+			ContentLength: 1 << 10,
+			Body:          pr,
+		}, nil
+	}), Logging())
+	req, err := NewRequest(ctx, http.MethodGet, "http://a.com/events", nil, nil)
+	require.NoError(t, err)
+	// 读取stream会阻塞到对端写入
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	go func() {
+		_, _ = pw.Write([]byte("data: 1\n\n"))
+	}()
+	event, err := NewEventReader(ctx, resp.Body).Next()
+	require.NoError(t, err)
+	require.Equal(t, "1", event.Data)
+	entries := logs.FilterMessage("http call").All()
+	require.Len(t, entries, 1)
+	require.NotContains(t, entries[0].ContextMap(), "response_body")
+}
+
+func TestCallTimeout(t *testing.T) {
+	c := Chain(ClientFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}), CallTimeout(10*time.Millisecond))
+	req, err := NewRequest(context.Background(), http.MethodGet, "http://a.com", nil, nil)
+	require.NoError(t, err)
+	_, err = c.Do(req)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+}
@@ -0,0 +1,215 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrMultipartRead the body of Multipart is read more than once
+var ErrMultipartRead = errors.New("multipart body has been read")
+
+// Multipart builds multipart/form-data body which streams files instead of loading them into memory
+type Multipart struct {
+	boundary string
+	parts    []part
+	readers  int32
+}
+
+type part struct {
+	header textproto.MIMEHeader
+	reader io.Reader
+	size   int64 // -1 means unknown
+	closer io.Closer
+}
+
+// NewMultipart returns an empty Multipart
+func NewMultipart() *Multipart {
+	return &Multipart{boundary: multipart.NewWriter(io.Discard).Boundary()}
+}
+
+// Field add a form field
+func (m *Multipart) Field(name, value string) *Multipart {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="`+escapeQuotes(name)+`"`)
+	m.parts = append(m.parts, part{header: header, reader: strings.NewReader(value), size: int64(len(value))})
+	return m
+}
+
+// File add a file read from r, size -1 means unknown and the body will be sent chunked
+func (m *Multipart) File(field, filename string, r io.Reader, size int64) *Multipart {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition",
+		`form-data; name="`+escapeQuotes(field)+`"; filename="`+escapeQuotes(filename)+`"`)
+	header.Set("Content-Type", "application/octet-stream")
+	m.parts = append(m.parts, part{header: header, reader: r, size: size})
+	return m
+}
+
+// FilePath add the file at path, it is opened now and closed after the body is read or aborted
+func (m *Multipart) FilePath(field, path string) (*Multipart, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var info os.FileInfo
+	if info, err = f.Stat(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	m.File(field, filepath.Base(path), f, info.Size())
+	m.parts[len(m.parts)-1].closer = f
+	return m, nil
+}
+
+// ContentType returns multipart/form-data with boundary
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// Size returns the body length, -1 if any part has unknown size
+func (m *Multipart) Size() int64 {
+	counter := &countingWriter{}
+	w := m.writer(counter)
+	var total int64
+	for _, p := range m.parts {
+		if p.size < 0 {
+			return -1
+		}
+		if _, err := w.CreatePart(p.header); err != nil {
+			return -1
+		}
+		total += p.size
+	}
+	if err := w.Close(); err != nil {
+		return -1
+	}
+	return total + counter.n
+}
+
+// Reader returns the body, parts are copied through a pipe from its first Read,
+// closing it aborts the copy and closes the files. It can be called only once,
+// the Read of later ones returns ErrMultipartRead
+func (m *Multipart) Reader() io.ReadCloser {
+	if atomic.AddInt32(&m.readers, 1) > 1 {
+		return io.NopCloser(errReader{err: ErrMultipartRead})
+	}
+	return &multipartBody{m: m}
+}
+
+// NewRequest returns a streaming request with the multipart body
+func (m *Multipart) NewRequest(ctx context.Context, method, uri string, headers http.Header) (*http.Request, error) {
+	header := headers.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Type", m.ContentType())
+	body := m.Reader()
+	req, err := NewStreamRequest(ctx, method, uri, body, m.Size(), header)
+	if err != nil {
+		_ = body.Close()
+		return nil, err
+	}
+	return req, nil
+}
+
+// multipartBody starts copying at the first Read so that nothing leaks if it is never read
+type multipartBody struct {
+	m      *Multipart
+	mutex  sync.Mutex
+	pr     *io.PipeReader
+	closed bool
+}
+
+func (b *multipartBody) Read(p []byte) (int, error) {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	if b.pr == nil {
+		pr, pw := io.Pipe()
+		b.pr = pr
+		go func() {
+			_ = pw.CloseWithError(b.m.writeTo(pw))
+		}()
+	}
+	pr := b.pr
+	b.mutex.Unlock()
+	return pr.Read(p)
+}
+
+func (b *multipartBody) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	if b.pr == nil {
+		// 未开始读取，直接关闭文件
+		b.m.close()
+		return nil
+	}
+	return b.pr.Close()
+}
+
+func (m *Multipart) writeTo(dst io.Writer) error {
+	defer m.close()
+	w := m.writer(dst)
+	for _, p := range m.parts {
+		pw, err := w.CreatePart(p.header)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(pw, p.reader); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func (m *Multipart) close() {
+	for _, p := range m.parts {
+		if p.closer != nil {
+			_ = p.closer.Close()
+		}
+	}
+}
+
+func (m *Multipart) writer(dst io.Writer) *multipart.Writer {
+	w := multipart.NewWriter(dst)
+	_ = w.SetBoundary(m.boundary)
+	return w
+}
+
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
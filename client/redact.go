@@ -0,0 +1,244 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const redacted = "******"
+
+// Redactor masks sensitive headers and json fields before they are logged
+type Redactor struct {
+	// Headers names are case-insensitive
+	Headers []string
+	// Fields json keys at any depth, case-insensitive
+	Fields []string
+	// MaxBody truncate body longer than it, 0 means no limit but logged responses are still cut at 1MB
+	MaxBody int
+}
+
+// DefaultRedactor masks common credentials and keeps 4KB of body
+func DefaultRedactor() Redactor {
+	return Redactor{
+		Headers: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Auth-Token"},
+		Fields: []string{"password", "passwd", "secret", "client_secret", "token",
+			"access_token", "refresh_token", "private_key"},
+		MaxBody: 4 << 10,
+	}
+}
+
+// Header returns a copy of h with sensitive values masked
+func (r Redactor) Header(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range r.Headers {
+		key := http.CanonicalHeaderKey(name)
+		if values, ok := out[key]; ok {
+			masked := make([]string, len(values))
+			for i := range masked {
+				masked[i] = redacted
+			}
+			out[key] = masked
+		}
+	}
+	return out
+}
+
+// URL returns a copy of u whose query parameters named by Fields are masked
+func (r Redactor) URL(u *url.URL) *url.URL {
+	out := *u
+	if u.User != nil {
+		out.User = url.User(u.User.Username())
+	}
+	if u.RawQuery == "" || len(r.Fields) == 0 {
+		return &out
+	}
+	query := u.Query()
+	for key, values := range query {
+		if r.sensitive(key) {
+			for i := range values {
+				values[i] = redacted
+			}
+		}
+	}
+	out.RawQuery = query.Encode()
+	return &out
+}
+
+// Body masks the fields when body is json and truncates it
+func (r Redactor) Body(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if len(r.Fields) > 0 {
+		body = r.maskJSON(body)
+	}
+	return r.truncate(body)
+}
+
+func (r Redactor) truncate(body []byte) string {
+	if r.MaxBody <= 0 || len(body) <= r.MaxBody {
+		return string(body)
+	}
+	return fmt.Sprintf("%s...(%d bytes truncated)", body[:r.MaxBody], len(body)-r.MaxBody)
+}
+
+// PartialBody is Body of the first part of a size bytes body, size < 0 means the size is unknown.
+// Body which is cut off is truncated before masking and its json keys are masked as text,
+// so that json cut off in the middle is masked too.
+func (r Redactor) PartialBody(body []byte, size int64) string {
+	if int64(len(body)) == size && (r.MaxBody <= 0 || len(body) <= r.MaxBody) {
+		return r.Body(body)
+	}
+	if r.MaxBody > 0 && len(body) > r.MaxBody {
+		body = body[:r.MaxBody]
+	}
+	kept := int64(len(body))
+	if len(r.Fields) > 0 {
+		body = r.maskText(body)
+	}
+	if size < 0 {
+		return fmt.Sprintf("%s...(truncated)", body)
+	}
+	return fmt.Sprintf("%s...(%d bytes truncated)", body, size-kept)
+}
+
+// maskJSON masks complete json, body which is not json is returned as it is
+func (r Redactor) maskJSON(body []byte) []byte {
+	var v interface{}
+	decoder := jsoniter.ConfigCompatibleWithStandardLibrary.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return body
+	}
+	data, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(r.mask(v))
+	if err != nil {
+		return body
+	}
+	return data
+}
+
+// maskText masks the values of sensitive json keys without parsing,
+// the value which is cut off is masked as a whole
+func (r Redactor) maskText(body []byte) []byte {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return body
+	}
+	out := make([]byte, 0, len(body))
+	for i := 0; i < len(body); {
+		if body[i] != '"' {
+			out = append(out, body[i])
+			i++
+			continue
+		}
+		end := skipString(body, i)
+		out = append(out, body[i:end]...)
+		j := skipSpace(body, end)
+		if j >= len(body) || body[j] != ':' || !r.sensitiveKey(body[i:end]) {
+			i = end
+			continue
+		}
+		j = skipSpace(body, j+1)
+		out = append(out, body[end:j]...)
+		if j < len(body) {
+			out = append(out, '"')
+			out = append(out, redacted...)
+			out = append(out, '"')
+		}
+		i = skipValue(body, j)
+	}
+	return out
+}
+
+func (r Redactor) sensitiveKey(quoted []byte) bool {
+	var key string
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(quoted, &key); err != nil {
+		return false
+	}
+	return r.sensitive(key)
+}
+
+// skipString returns the index after the string starting at data[i], len(data) when it is cut off
+func skipString(data []byte, i int) int {
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(data)
+}
+
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && strings.IndexByte(" \t\r\n", data[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// skipValue returns the index after the json value starting at data[i]
+func skipValue(data []byte, i int) int {
+	if i >= len(data) {
+		return i
+	}
+	switch data[i] {
+	case '"':
+		return skipString(data, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(data); {
+			switch data[j] {
+			case '"':
+				j = skipString(data, j)
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth == 0 {
+					return j + 1
+				}
+			}
+			j++
+		}
+		return len(data)
+	}
+	j := i
+	for j < len(data) && strings.IndexByte(",}] \t\r\n", data[j]) < 0 {
+		j++
+	}
+	return j
+}
+
+func (r Redactor) mask(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if r.sensitive(key) {
+				value[key] = redacted
+				continue
+			}
+			value[key] = r.mask(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = r.mask(item)
+		}
+	}
+	return v
+}
+
+func (r Redactor) sensitive(key string) bool {
+	for _, field := range r.Fields {
+		if strings.EqualFold(field, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,11 +3,8 @@ package client
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"net/http"
-
-	"moul.io/http2curl"
-
-	"github.com/crochee/lirity/logger"
 )
 
 var DefaultClient Client = NewStandardClient()
@@ -34,17 +31,16 @@ func NewRequest(ctx context.Context, method string, uri string,
 	if err != nil {
 		return nil, err
 	}
+	// URLHandler没有可用的endpoint
+	if req.URL.Scheme == noEndpointScheme {
+		return nil, fmt.Errorf("%w for %s", ErrNoEndpoint, req.URL.Path)
+	}
 	for key, header := range headers {
 		for _, value := range header {
 			req.Header.Add(key, value)
 		}
 	}
 	// 打印curl语句，便于问题分析和定位
-	var curl *http2curl.CurlCommand
-	if curl, err = http2curl.GetCurlCommand(req); err == nil {
-		logger.From(ctx).Debug(curl.String())
-	} else {
-		logger.From(ctx).Error(err.Error())
-	}
+	dumpCurl(ctx, req, body)
 	return req, nil
 }
@@ -0,0 +1,206 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/crochee/lirity/e"
+)
+
+type RESTOption struct {
+	Client      Client
+	JSONHandler jsoniter.API
+	URLHandler  URLHandler
+	// ErrorCode is the prototype the error response is decoded into
+	ErrorCode e.ErrorCode
+}
+
+// NewRESTClient returns a json REST client
+func NewRESTClient(opts ...func(*RESTOption)) *RESTClient {
+	o := RESTOption{
+		Client:      DefaultClient,
+		JSONHandler: jsoniter.ConfigCompatibleWithStandardLibrary,
+		URLHandler:  NewURLHandler(),
+		ErrorCode:   &e.ErrCode{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &RESTClient{RESTOption: o}
+}
+
+type RESTClient struct {
+	RESTOption
+}
+
+type callOption struct {
+	query       url.Values
+	header      http.Header
+	statusCodes []int
+	errorCode   e.ErrorCode
+}
+
+type CallOption func(*callOption)
+
+// WithQuery set url query
+func WithQuery(value url.Values) CallOption {
+	return func(o *callOption) { o.query = value }
+}
+
+// WithHeader set request header
+func WithHeader(header http.Header) CallOption {
+	return func(o *callOption) { o.header = header }
+}
+
+// WithStatus set the acceptable status codes, default is 200,201,202,204
+func WithStatus(codes ...int) CallOption {
+	return func(o *callOption) { o.statusCodes = codes }
+}
+
+// WithErrorCode overrides RESTOption.ErrorCode for one call
+func WithErrorCode(code e.ErrorCode) CallOption {
+	return func(o *callOption) { o.errorCode = code }
+}
+
+func (r *RESTClient) Get(ctx context.Context, path string, result interface{}, opts ...CallOption) error {
+	return r.Call(ctx, http.MethodGet, path, nil, result, opts...)
+}
+
+func (r *RESTClient) Post(ctx context.Context, path string, body, result interface{}, opts ...CallOption) error {
+	return r.Call(ctx, http.MethodPost, path, body, result, opts...)
+}
+
+func (r *RESTClient) Put(ctx context.Context, path string, body, result interface{}, opts ...CallOption) error {
+	return r.Call(ctx, http.MethodPut, path, body, result, opts...)
+}
+
+func (r *RESTClient) Patch(ctx context.Context, path string, body, result interface{}, opts ...CallOption) error {
+	return r.Call(ctx, http.MethodPatch, path, body, result, opts...)
+}
+
+func (r *RESTClient) Delete(ctx context.Context, path string, result interface{}, opts ...CallOption) error {
+	return r.Call(ctx, http.MethodDelete, path, nil, result, opts...)
+}
+
+// Call sends body as json and decodes the response into result when the status code is acceptable,
+// otherwise decodes it into a copy of the ErrorCode prototype and returns it.
+// result is ignored when it is nil or the response has no content.
+func (r *RESTClient) Call(ctx context.Context, method, path string, body, result interface{},
+	opts ...CallOption) error {
+	o := callOption{
+		statusCodes: []int{http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent},
+		errorCode:   r.ErrorCode,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var (
+		data []byte
+		err  error
+	)
+	header := r.URLHandler.Header(ctx, o.header)
+	if body != nil {
+		if data, err = r.JSONHandler.Marshal(body); err != nil {
+			return err
+		}
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", "application/json")
+		}
+	}
+	var req *http.Request
+	if req, err = NewRequest(ctx, method, r.URLHandler.URLWithQuery(ctx, path, o.query), data, header); err != nil {
+		return err
+	}
+	var resp *http.Response
+	if resp, err = r.Client.Do(req); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if !containsCode(o.statusCodes, resp.StatusCode) {
+		return r.decodeError(resp, o.statusCodes, o.errorCode)
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent || resp.ContentLength == 0 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err = r.JSONHandler.NewDecoder(resp.Body).Decode(result); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func (r *RESTClient) decodeError(resp *http.Response, want []int, prototype e.ErrorCode) error {
+	code := newErrorCode(prototype)
+	if err := r.JSONHandler.NewDecoder(resp.Body).Decode(code); err != nil {
+		return fmt.Errorf("http code %d,but not %v,%w", resp.StatusCode, want, err)
+	}
+	if len(code.Code()) < 3 {
+		return fmt.Errorf("http code %d,but not %v,%w", resp.StatusCode, want, code)
+	}
+	return code.WithStatusCode(resp.StatusCode)
+}
+
+// newErrorCode returns a zero value of the prototype's type so that the prototype is never modified
+func newErrorCode(prototype e.ErrorCode) e.ErrorCode {
+	t := reflect.TypeOf(prototype)
+	if t.Kind() != reflect.Ptr {
+		return prototype
+	}
+	code, ok := reflect.New(t.Elem()).Interface().(e.ErrorCode)
+	if !ok {
+		return prototype
+	}
+	return code
+}
+
+func containsCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Resource decodes responses into T
+type Resource[T any] struct {
+	client *RESTClient
+	path   string
+}
+
+// NewResource returns Resource whose paths are relative to basePath
+func NewResource[T any](c *RESTClient, basePath string) Resource[T] {
+	return Resource[T]{client: c, path: basePath}
+}
+
+func (r Resource[T]) Get(ctx context.Context, path string, opts ...CallOption) (T, error) {
+	return r.Call(ctx, http.MethodGet, path, nil, opts...)
+}
+
+func (r Resource[T]) Post(ctx context.Context, path string, body interface{}, opts ...CallOption) (T, error) {
+	return r.Call(ctx, http.MethodPost, path, body, opts...)
+}
+
+func (r Resource[T]) Put(ctx context.Context, path string, body interface{}, opts ...CallOption) (T, error) {
+	return r.Call(ctx, http.MethodPut, path, body, opts...)
+}
+
+func (r Resource[T]) Patch(ctx context.Context, path string, body interface{}, opts ...CallOption) (T, error) {
+	return r.Call(ctx, http.MethodPatch, path, body, opts...)
+}
+
+func (r Resource[T]) Delete(ctx context.Context, path string, opts ...CallOption) (T, error) {
+	return r.Call(ctx, http.MethodDelete, path, nil, opts...)
+}
+
+func (r Resource[T]) Call(ctx context.Context, method, path string, body interface{}, opts ...CallOption) (T, error) {
+	var result T
+	err := r.client.Call(ctx, method, r.path+path, body, &result, opts...)
+	return result, err
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/crochee/lirity/e"
+)
+
+type restURL struct {
+	DefaultIP
+	host string
+}
+
+func (r restURL) URL(_ context.Context, path string) string {
+	return r.host + path
+}
+
+func (r restURL) URLWithQuery(ctx context.Context, path string, value url.Values) string {
+	return r.URL(ctx, path)
+}
+
+type user struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestRESTClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/users/1":
+			_, _ = w.Write([]byte(`{"id":"1","name":"a"}`))
+		case "POST /v1/users":
+			body, _ := io.ReadAll(r.Body)
+			require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write(body)
+		case "DELETE /v1/users/1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"code":"4040010001","message":"user not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	c := NewRESTClient(func(o *RESTOption) {
+		o.Client = NewStandardClient()
+		o.URLHandler = restURL{host: server.URL}
+	})
+	users := NewResource[user](c, "/v1/users")
+	ctx := context.Background()
+
+	u, err := users.Get(ctx, "/1")
+	require.NoError(t, err)
+	require.Equal(t, user{ID: "1", Name: "a"}, u)
+
+	u, err = users.Post(ctx, "", user{ID: "2", Name: "b"}, WithStatus(http.StatusCreated))
+	require.NoError(t, err)
+	require.Equal(t, user{ID: "2", Name: "b"}, u)
+
+	_, err = users.Delete(ctx, "/1")
+	require.NoError(t, err)
+
+	_, err = users.Get(ctx, "/2")
+	var code e.ErrorCode
+	require.True(t, errors.As(err, &code))
+	require.Equal(t, "4040010001", code.Code())
+	require.Equal(t, "user not found", code.Message())
+	require.Equal(t, "", c.ErrorCode.Code())
+
+	err = c.Get(ctx, "/v1/users/1", nil, WithStatus(http.StatusAccepted))
+	require.Error(t, err)
+}
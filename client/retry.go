@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Backoff returns how long to wait before the attempt, attempt starts from 1
+type Backoff interface {
+	Next(attempt int) time.Duration
+}
+
+// ExponentialBackoff grows Base*2^(attempt-1) up to Max with Jitter randomization in [0,1],
+// zero Max means the max time.Duration
+type ExponentialBackoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+}
+
+func (e ExponentialBackoff) Next(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := float64(e.Base) * math.Pow(2, float64(attempt-1))
+	limit := time.Duration(math.MaxInt64)
+	if e.Max > 0 {
+		limit = e.Max
+	}
+	// 超出int64的float64转换为Duration会溢出为负数
+	if d >= float64(limit) {
+		d = float64(limit)
+	}
+	if e.Jitter > 0 {
+		d -= d * e.Jitter * rand.Float64() // nolint:gosec
+	}
+	if d >= float64(limit) {
+		return limit
+	}
+	return time.Duration(d)
+}
+
+// RetryBudget limits retries to a ratio of requests so that retries never amplify load unbounded
+type RetryBudget struct {
+	mutex   sync.Mutex
+	ratio   float64
+	balance float64
+	max     float64
+}
+
+// retryBudgetWindow is how many requests worth of deposits RetryBudget can save
+const retryBudgetWindow = 100
+
+// NewRetryBudget every request deposits ratio token and every retry withdraws one token,
+// minRetries tokens are available at the beginning.
+// Saved tokens are capped at minRetries plus the deposits of 100 requests,
+// so the retries after a quiet period are at most minRetries+ratio*100.
+func NewRetryBudget(ratio float64, minRetries int) *RetryBudget {
+	return &RetryBudget{
+		ratio:   ratio,
+		balance: float64(minRetries),
+		max:     float64(minRetries) + ratio*retryBudgetWindow,
+	}
+}
+
+func (r *RetryBudget) deposit() {
+	r.mutex.Lock()
+	r.balance = math.Min(r.balance+r.ratio, r.max)
+	r.mutex.Unlock()
+}
+
+func (r *RetryBudget) withdraw() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.balance < 1 {
+		return false
+	}
+	r.balance--
+	return true
+}
+
+type RetryOption struct {
+	// MaxAttempts contains the first attempt
+	MaxAttempts int
+	Backoff     Backoff
+	// Retryable decide whether the attempt should be retried
+	Retryable func(resp *http.Response, err error) bool
+	// MaxRetryAfter caps the wait read from Retry-After header, 0 means ignore Retry-After
+	MaxRetryAfter time.Duration
+	// Budget nil means no budget
+	Budget *RetryBudget
+	// AllowNonIdempotent retry POST,PATCH... which have no Idempotency-Key header
+	AllowNonIdempotent bool
+}
+
+// NewRetryClient wraps c to retry failed requests
+func NewRetryClient(c Client, opts ...func(*RetryOption)) Client {
+	o := RetryOption{
+		MaxAttempts: 3,
+		Backoff: ExponentialBackoff{
+			Base:   100 * time.Millisecond,
+			Max:    10 * time.Second,
+			Jitter: 0.2,
+		},
+		Retryable:     DefaultRetryable,
+		MaxRetryAfter: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &retryClient{client: c, RetryOption: o}
+}
+
+// DefaultRetryable retries network errors and 429,502,503,504
+func DefaultRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+type retryClient struct {
+	client Client
+	RetryOption
+}
+
+func (r *retryClient) Do(req *http.Request) (*http.Response, error) {
+	if r.Budget != nil {
+		r.Budget.deposit()
+	}
+	retryable := r.canRetry(req)
+	for attempt := 1; ; attempt++ {
+		resp, err := r.client.Do(req)
+		if !retryable || attempt >= r.MaxAttempts || !r.Retryable(resp, err) {
+			return resp, err
+		}
+		if r.Budget != nil && !r.Budget.withdraw() {
+			return resp, err
+		}
+		wait := r.Backoff.Next(attempt)
+		if resp != nil {
+			if after := r.retryAfter(resp); after > wait {
+				wait = after
+			}
+			// 读完并关闭body才能复用连接
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		if err = sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *retryClient) canRetry(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	return r.AllowNonIdempotent || isIdempotent(req)
+}
+
+func (r *retryClient) retryAfter(resp *http.Response) time.Duration {
+	if r.MaxRetryAfter <= 0 {
+		return 0
+	}
+	d := ParseRetryAfter(resp.Header.Get("Retry-After"))
+	if d > r.MaxRetryAfter {
+		return r.MaxRetryAfter
+	}
+	return d
+}
+
+// ParseRetryAfter parse Retry-After header in seconds or http date
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// rewind returns a shallow copy of req with a fresh body
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.GetBody == nil || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	newReq := req.Clone(req.Context())
+	newReq.Body = body
+	return newReq, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryClient(t *testing.T) {
+	testList := []struct {
+		name     string
+		method   string
+		codes    []int
+		opts     []func(*RetryOption)
+		expected int
+		attempts int32
+	}{
+		{
+			name:     "retry then success",
+			method:   http.MethodPut,
+			codes:    []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			expected: http.StatusOK,
+			attempts: 3,
+		},
+		{
+			name:     "max attempts",
+			method:   http.MethodGet,
+			codes:    []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests},
+			opts:     []func(*RetryOption){func(o *RetryOption) { o.MaxAttempts = 2 }},
+			expected: http.StatusTooManyRequests,
+			attempts: 2,
+		},
+		{
+			name:     "not retryable status",
+			method:   http.MethodGet,
+			codes:    []int{http.StatusInternalServerError, http.StatusOK},
+			expected: http.StatusInternalServerError,
+			attempts: 1,
+		},
+		{
+			name:     "post is not idempotent",
+			method:   http.MethodPost,
+			codes:    []int{http.StatusServiceUnavailable, http.StatusOK},
+			expected: http.StatusServiceUnavailable,
+			attempts: 1,
+		},
+		{
+			name:     "allow post",
+			method:   http.MethodPost,
+			codes:    []int{http.StatusServiceUnavailable, http.StatusOK},
+			opts:     []func(*RetryOption){func(o *RetryOption) { o.AllowNonIdempotent = true }},
+			expected: http.StatusOK,
+			attempts: 2,
+		},
+		{
+			name:     "budget exhausted",
+			method:   http.MethodGet,
+			codes:    []int{http.StatusServiceUnavailable, http.StatusOK},
+			opts:     []func(*RetryOption){func(o *RetryOption) { o.Budget = NewRetryBudget(0, 0) }},
+			expected: http.StatusServiceUnavailable,
+			attempts: 1,
+		},
+	}
+	for _, tt := range testList {
+		t.Run(tt.name, func(t *testing.T) {
+			var count int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, "payload", string(body))
+				i := atomic.AddInt32(&count, 1) - 1
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(tt.codes[i])
+			}))
+			defer server.Close()
+
+			opts := append([]func(*RetryOption){func(o *RetryOption) {
+				o.Backoff = ExponentialBackoff{Base: time.Millisecond}
+			}}, tt.opts...)
+			c := NewRetryClient(NewStandardClient(), opts...)
+			req, err := NewRequest(context.Background(), tt.method, server.URL, []byte("payload"), nil)
+			require.NoError(t, err)
+			resp, err := c.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.expected, resp.StatusCode)
+			require.Equal(t, tt.attempts, atomic.LoadInt32(&count))
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	require.Equal(t, 3*time.Second, ParseRetryAfter("3"))
+	require.Equal(t, time.Duration(0), ParseRetryAfter("-1"))
+	require.Equal(t, time.Duration(0), ParseRetryAfter("bad"))
+	d := ParseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	require.True(t, d > 50*time.Second && d <= time.Minute, d)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff{Base: time.Second}
+	require.Equal(t, time.Second, b.Next(0))
+	require.Equal(t, 4*time.Second, b.Next(3))
+	for _, attempt := range []int{64, 100, 2000} {
+		require.Equal(t, time.Duration(math.MaxInt64), b.Next(attempt))
+	}
+	b = ExponentialBackoff{Base: time.Second, Max: time.Minute, Jitter: 0.5}
+	for attempt := 1; attempt < 2000; attempt *= 2 {
+		d := b.Next(attempt)
+		require.True(t, d > 0 && d <= time.Minute, d)
+	}
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// NewStreamRequest create request whose body is read while it is sent,
+// size -1 means unknown length and the body is sent chunked
+func NewStreamRequest(ctx context.Context, method string, uri string,
+	body io.Reader, size int64, headers http.Header) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	}
+	for key, header := range headers {
+		for _, value := range header {
+			req.Header.Add(key, value)
+		}
+	}
+	// body只能读取一次，不打印
+	dumpCurl(ctx, req, nil)
+	return req, nil
+}
+
+// closeOnDone closes body when ctx is done so that the blocked Read returns,
+// the returned func must be called to release the goroutine
+func closeOnDone(ctx context.Context, body io.Closer) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = body.Close()
+		case <-stop:
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(stop) }) }
+}
+
+// NDJSONDecoder iterates newline delimited json of a response body
+type NDJSONDecoder struct {
+	ctx     context.Context
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	api     jsoniter.API
+	stop    func()
+}
+
+// NewNDJSONDecoder reads body until ctx is done, lines longer than maxLine fail, maxLine 0 means 1MB
+func NewNDJSONDecoder(ctx context.Context, body io.ReadCloser, maxLine int) *NDJSONDecoder {
+	if maxLine <= 0 {
+		maxLine = 1 << 20
+	}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), maxLine)
+	return &NDJSONDecoder{
+		ctx:     ctx,
+		body:    body,
+		scanner: scanner,
+		api:     jsoniter.ConfigCompatibleWithStandardLibrary,
+		stop:    closeOnDone(ctx, body),
+	}
+}
+
+// Next decodes the next non-empty line into v, returns io.EOF at the end
+func (n *NDJSONDecoder) Next(v interface{}) error {
+	for n.scanner.Scan() {
+		line := bytes.TrimSpace(n.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		return n.api.Unmarshal(line, v)
+	}
+	if err := n.ctx.Err(); err != nil {
+		return err
+	}
+	if err := n.scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// Close closes the body
+func (n *NDJSONDecoder) Close() error {
+	n.stop()
+	return n.body.Close()
+}
+
+// Event is a server-sent event
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// EventReader reads text/event-stream of a response body
+type EventReader struct {
+	ctx     context.Context
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	stop    func()
+	// LastEventID is the id of the last event, send it as Last-Event-ID when reconnecting
+	LastEventID string
+}
+
+// NewEventReader reads body until ctx is done
+func NewEventReader(ctx context.Context, body io.ReadCloser) *EventReader {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	return &EventReader{
+		ctx:     ctx,
+		body:    body,
+		scanner: scanner,
+		stop:    closeOnDone(ctx, body),
+	}
+}
+
+// Next returns the next event, returns io.EOF at the end
+func (e *EventReader) Next() (*Event, error) {
+	var (
+		event   Event
+		data    strings.Builder
+		hasData bool
+	)
+	for e.scanner.Scan() {
+		line := e.scanner.Text()
+		if line == "" {
+			if !hasData {
+				continue
+			}
+			event.Data = strings.TrimSuffix(data.String(), "\n")
+			event.ID = e.LastEventID
+			return &event, nil
+		}
+		if strings.HasPrefix(line, ":") { // comment
+			continue
+		}
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				e.LastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil {
+				event.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	if err := e.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := e.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Close closes the body
+func (e *EventReader) Close() error {
+	e.stop()
+	return e.body.Close()
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultipart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	require.NoError(t, os.WriteFile(path, []byte("file content"), 0600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		require.Equal(t, "v", r.FormValue("k"))
+		f, header, err := r.FormFile("upload")
+		require.NoError(t, err)
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		require.NoError(t, err)
+		require.Equal(t, "a.txt", header.Filename)
+		require.Equal(t, "file content", string(data))
+		_, _ = w.Write([]byte(r.Header.Get("Content-Length")))
+	}))
+	defer server.Close()
+
+	for _, known := range []bool{true, false} {
+		m := NewMultipart().Field("k", "v")
+		if known {
+			var err error
+			m, err = m.FilePath("upload", path)
+			require.NoError(t, err)
+		} else {
+			m.File("upload", "a.txt", io.MultiReader(strings.NewReader("file content")), -1)
+		}
+		req, err := m.NewRequest(context.Background(), http.MethodPost, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := NewStandardClient().Do(req)
+		require.NoError(t, err)
+		length, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		if known {
+			require.NotEqual(t, int64(-1), m.Size())
+			require.Equal(t, m.Size(), req.ContentLength)
+			require.NotEmpty(t, string(length))
+		} else {
+			require.Equal(t, int64(-1), m.Size())
+			require.Empty(t, string(length))
+		}
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed int32
+}
+
+func (c *closeRecorder) Close() error {
+	atomic.AddInt32(&c.closed, 1)
+	return nil
+}
+
+func TestMultipartAbort(t *testing.T) {
+	file := &closeRecorder{Reader: strings.NewReader("file content")}
+	m := NewMultipart().File("upload", "a.txt", file, 12)
+	m.parts[0].closer = file
+	_, err := m.NewRequest(context.Background(), http.MethodPost, "://bad", nil)
+	require.Error(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&file.closed))
+
+	// 第二次读取返回错误而不是空body
+	_, err = io.ReadAll(m.Reader())
+	require.True(t, errors.Is(err, ErrMultipartRead))
+
+	file = &closeRecorder{Reader: strings.NewReader("file content")}
+	m = NewMultipart().File("upload", "a.txt", file, 12)
+	m.parts[0].closer = file
+	body := m.Reader()
+	buf := make([]byte, 8)
+	_, err = body.Read(buf)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&file.closed) == 1
+	}, time.Second, 10*time.Millisecond)
+	_, err = body.Read(buf)
+	require.Error(t, err)
+}
+
+func TestNDJSONDecoder(t *testing.T) {
+	d := NewNDJSONDecoder(context.Background(), io.NopCloser(strings.NewReader("{\"id\":1}\n\n{\"id\":2}\n")), 0)
+	defer d.Close()
+	var ids []int
+	for {
+		var v struct {
+			ID int `json:"id"`
+		}
+		err := d.Next(&v)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		ids = append(ids, v.ID)
+	}
+	require.Equal(t, []int{1, 2}, ids)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	d = NewNDJSONDecoder(ctx, pr, 0)
+	var v interface{}
+	require.True(t, errors.Is(d.Next(&v), context.DeadlineExceeded))
+	require.NoError(t, d.Close())
+}
+
+func TestEventReader(t *testing.T) {
+	r := NewEventReader(context.Background(), io.NopCloser(strings.NewReader(
+		": comment\nevent: add\nid: 1\ndata: a\ndata: b\n\nretry: 3000\ndata: c\n\n")))
+	defer r.Close()
+	event, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, &Event{ID: "1", Event: "add", Data: "a\nb"}, event)
+	event, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, &Event{ID: "1", Data: "c", Retry: 3 * time.Second}, event)
+	_, err = r.Next()
+	require.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/crochee/lirity"
+)
+
+type TLSOption struct {
+	MinVersion uint16
+	// ReloadInterval how often to check whether the files changed, checks happen on handshakes
+	ReloadInterval time.Duration
+	// OnReload is called after the files are reloaded, err is not nil when the new files are invalid
+	// and the previous ones are still used. It is nil by default, set it to log the errors, e.g.
+	//
+	//	o.OnReload = func(err error) {
+	//		if err != nil {
+	//			logger.From(ctx).Error(err.Error())
+	//		}
+	//	}
+	OnReload func(err error)
+}
+
+// NewTLSConfig build tls config for TLSConfig from cert, key and ca which are file paths or contents.
+// Empty cert and key means no client certificate, empty ca means system roots.
+// Files are reloaded when they change, so rotated certificates are used by new connections
+// while idle connections are kept.
+func NewTLSConfig(cert, key, ca lirity.FileOrContent, opts ...func(*TLSOption)) (*tls.Config, error) {
+	o := TLSOption{
+		MinVersion:     tls.VersionTLS12,
+		ReloadInterval: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if (cert == "") != (key == "") {
+		return nil, errors.New("cert and key must be set together")
+	}
+	r := &tlsReloader{cert: cert, key: key, ca: ca, option: o}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{MinVersion: o.MinVersion} // nolint:gosec
+	if cert != "" {
+		cfg.GetClientCertificate = r.getClientCertificate
+	}
+	if ca != "" {
+		// RootCAs can't be replaced on the fly, so verify by ourselves with the current pool
+		cfg.InsecureSkipVerify = true // nolint:gosec
+		cfg.VerifyConnection = r.verifyConnection
+	}
+	return cfg, nil
+}
+
+type tlsReloader struct {
+	cert, key, ca lirity.FileOrContent
+	option        TLSOption
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+	pool        *x509.CertPool
+	checkedAt   time.Time
+	modified    map[string]time.Time
+}
+
+func (r *tlsReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.reloadIfChanged()
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.certificate, nil
+}
+
+func (r *tlsReloader) verifyConnection(cs tls.ConnectionState) error {
+	r.reloadIfChanged()
+	r.mutex.RLock()
+	pool := r.pool
+	r.mutex.RUnlock()
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: server sent no certificate")
+	}
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         pool,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+func (r *tlsReloader) reloadIfChanged() {
+	r.mutex.Lock()
+	if time.Since(r.checkedAt) < r.option.ReloadInterval {
+		r.mutex.Unlock()
+		return
+	}
+	r.checkedAt = time.Now()
+	changed := false
+	for _, f := range []lirity.FileOrContent{r.cert, r.key, r.ca} {
+		if modTime, ok := modifiedAt(f); ok && !modTime.Equal(r.modified[f.String()]) {
+			changed = true
+		}
+	}
+	r.mutex.Unlock()
+	if !changed {
+		return
+	}
+	err := r.load()
+	if r.option.OnReload != nil {
+		r.option.OnReload(err)
+	}
+}
+
+// load reads the files and replaces certificate and pool when they are valid
+func (r *tlsReloader) load() error {
+	modified := make(map[string]time.Time, 3)
+	for _, f := range []lirity.FileOrContent{r.cert, r.key, r.ca} {
+		if modTime, ok := modifiedAt(f); ok {
+			modified[f.String()] = modTime
+		}
+	}
+	var certificate *tls.Certificate
+	if r.cert != "" {
+		certPEM, err := r.cert.Read()
+		if err != nil {
+			return err
+		}
+		var keyPEM []byte
+		if keyPEM, err = r.key.Read(); err != nil {
+			return err
+		}
+		var pair tls.Certificate
+		if pair, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+			return err
+		}
+		certificate = &pair
+	}
+	var pool *x509.CertPool
+	if r.ca != "" {
+		caPEM, err := r.ca.Read()
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return errors.New("no valid certificate in ca")
+		}
+	}
+	r.mutex.Lock()
+	r.certificate = certificate
+	r.pool = pool
+	r.modified = modified
+	r.checkedAt = time.Now()
+	r.mutex.Unlock()
+	return nil
+}
+
+func modifiedAt(f lirity.FileOrContent) (time.Time, bool) {
+	if f == "" {
+		return time.Time{}, false
+	}
+	info, err := os.Stat(f.String())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
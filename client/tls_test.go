@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/crochee/lirity"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	serverCert := newTestCert(t, "server", ca)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	pair, err := tls.X509KeyPair(serverCert.certPEM, serverCert.keyPEM)
+	require.NoError(t, err)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	write := func(c *testCert, modTime time.Time) {
+		require.NoError(t, os.WriteFile(certPath, c.certPEM, 0600))
+		require.NoError(t, os.WriteFile(keyPath, c.keyPEM, 0600))
+		require.NoError(t, os.Chtimes(certPath, modTime, modTime))
+		require.NoError(t, os.Chtimes(keyPath, modTime, modTime))
+	}
+	write(newTestCert(t, "client1", ca), time.Now().Add(-time.Minute))
+
+	reloaded := make(chan error, 1)
+	cfg, err := NewTLSConfig(lirity.FileOrContent(certPath), lirity.FileOrContent(keyPath),
+		lirity.FileOrContent(ca.certPEM), func(o *TLSOption) {
+			o.ReloadInterval = 0
+			o.OnReload = func(err error) { reloaded <- err }
+		})
+	require.NoError(t, err)
+	c := NewStandardClient(TLSConfig(cfg))
+	get := func() string {
+		req, err := NewRequest(context.Background(), http.MethodGet, server.URL, nil, nil)
+		require.NoError(t, err)
+		req.Close = true // force a new handshake
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+	require.Equal(t, "client1", get())
+
+	write(newTestCert(t, "client2", ca), time.Now())
+	require.Equal(t, "client2", get())
+	require.NoError(t, <-reloaded)
+
+	// a server signed by another ca is rejected
+	other := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer other.Close()
+	req, err := NewRequest(context.Background(), http.MethodGet, other.URL, nil, nil)
+	require.NoError(t, err)
+	_, err = c.Do(req)
+	require.Error(t, err)
+}
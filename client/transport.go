@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResponseHeaderTimeout config how long to wait for the response headers after the request is written
+func ResponseHeaderTimeout(t time.Duration) Option {
+	return func(o *option) { o.t.ResponseHeaderTimeout = t }
+}
+
+// DialTimeout config the timeout of establishing a connection
+func DialTimeout(t time.Duration) Option {
+	return func(o *option) { o.dialer.Timeout = t }
+}
+
+// KeepAlive config tcp keep-alive period, negative disables it
+func KeepAlive(t time.Duration) Option {
+	return func(o *option) { o.dialer.KeepAlive = t }
+}
+
+// DisableKeepAlives use a connection per request
+func DisableKeepAlives() Option {
+	return func(o *option) { o.t.DisableKeepAlives = true }
+}
+
+// MaxIdleConns config the idle connections of all hosts, 0 means no limit
+func MaxIdleConns(n int) Option {
+	return func(o *option) { o.t.MaxIdleConns = n }
+}
+
+// MaxIdleConnsPerHost config the idle connections of every host
+func MaxIdleConnsPerHost(n int) Option {
+	return func(o *option) { o.t.MaxIdleConnsPerHost = n }
+}
+
+// MaxConnsPerHost config the connections of every host including dialing, active and idle, 0 means no limit
+func MaxConnsPerHost(n int) Option {
+	return func(o *option) { o.t.MaxConnsPerHost = n }
+}
+
+// IdleConnTimeout config how long an idle connection is kept
+func IdleConnTimeout(t time.Duration) Option {
+	return func(o *option) { o.t.IdleConnTimeout = t }
+}
+
+// HTTP2 enable or disable http/2 over tls
+func HTTP2(enable bool) Option {
+	return func(o *option) {
+		o.t.ForceAttemptHTTP2 = enable
+		if enable {
+			o.t.TLSNextProto = nil
+		} else {
+			// 非nil的空map禁用http2
+			o.t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		}
+	}
+}
+
+// Proxy config the proxy func, see http.Transport.Proxy
+func Proxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(o *option) { o.t.Proxy = proxy }
+}
+
+// ProxyFromEnvironment use HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+func ProxyFromEnvironment() Option {
+	return Proxy(http.ProxyFromEnvironment)
+}
+
+// ProxyURL sends requests through proxy except hosts matching noProxy,
+// noProxy entries are host, host:port, .domain suffix, domain with its subdomains, CIDR or *
+func ProxyURL(proxy *url.URL, noProxy ...string) Option {
+	return Proxy(func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL, noProxy) {
+			return nil, nil
+		}
+		return proxy, nil
+	})
+}
+
+func bypassProxy(u *url.URL, noProxy []string) bool {
+	host, port := u.Hostname(), u.Port()
+	ip := net.ParseIP(host)
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, ipNet, err := net.ParseCIDR(entry); err == nil && ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+		default:
+			entryHost, entryPort, err := net.SplitHostPort(entry)
+			if err != nil {
+				entryHost, entryPort = entry, ""
+			}
+			if entryPort != "" && entryPort != port {
+				continue
+			}
+			host = strings.ToLower(host)
+			if strings.HasPrefix(entryHost, ".") {
+				if strings.HasSuffix(host, entryHost) {
+					return true
+				}
+				continue
+			}
+			if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UnixSocket dials the unix socket at path for every request, the host of url is ignored
+func UnixSocket(path string) Option {
+	return func(o *option) { o.unix = path }
+}
+
+// Resolver config the dns resolver
+func Resolver(r *net.Resolver) Option {
+	return func(o *option) { o.dialer.Resolver = r }
+}
+
+// DNSCache caches resolved addresses for ttl
+func DNSCache(ttl time.Duration) Option {
+	return func(o *option) { o.dnsCache = ttl }
+}
+
+func (o *option) dialContext() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := o.dialer
+	if o.unix != "" {
+		path := o.unix
+		return func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		}
+	}
+	if o.dnsCache <= 0 {
+		return dialer.DialContext
+	}
+	var resolver ipResolver = net.DefaultResolver
+	if o.lookup != nil {
+		resolver = o.lookup
+	} else if dialer.Resolver != nil {
+		resolver = dialer.Resolver
+	}
+	cache := &dnsCache{resolver: resolver, ttl: o.dnsCache, entries: make(map[string]dnsEntry)}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		var ips []string
+		if ips, err = cache.lookup(ctx, host); err != nil {
+			return nil, err
+		}
+		var errs error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = err
+		}
+		// 全部失败可能是地址已变化，下次重新解析
+		cache.forget(host)
+		return nil, errs
+	}
+}
+
+type dnsEntry struct {
+	ips       []string
+	expiredAt time.Time
+}
+
+// ipResolver is implemented by *net.Resolver
+type ipResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+type dnsCache struct {
+	resolver ipResolver
+	ttl      time.Duration
+
+	mutex   sync.RWMutex
+	entries map[string]dnsEntry
+}
+
+func (d *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	d.mutex.RLock()
+	entry, ok := d.entries[host]
+	d.mutex.RUnlock()
+	if ok && time.Now().Before(entry.expiredAt) {
+		return entry.ips, nil
+	}
+	addrs, err := d.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no address of " + host)
+	}
+	ips := make([]string, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP.String()
+	}
+	d.mutex.Lock()
+	d.entries[host] = dnsEntry{ips: ips, expiredAt: time.Now().Add(d.ttl)}
+	d.mutex.Unlock()
+	return ips, nil
+}
+
+func (d *dnsCache) forget(host string) {
+	d.mutex.Lock()
+	delete(d.entries, host)
+	d.mutex.Unlock()
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBypassProxy(t *testing.T) {
+	noProxy := []string{"localhost", ".internal.com", "example.com:8080", "10.0.0.0/8"}
+	for raw, expected := range map[string]bool{
+		"http://localhost/a":          true,
+		"http://a.internal.com":       true,
+		"http://internal.com":         false,
+		"http://example.com:8080":     true,
+		"http://api.example.com:8080": true,
+		"http://example.com":          false,
+		"http://10.1.2.3":             true,
+		"http://11.1.2.3":             false,
+	} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		require.Equal(t, expected, bypassProxy(u, noProxy), raw)
+	}
+	u, _ := url.Parse("http://any.com")
+	require.True(t, bypassProxy(u, []string{"*"}))
+}
+
+func TestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("late"))
+	}))
+	defer server.Close()
+	req, err := NewRequest(context.Background(), http.MethodGet, server.URL, nil, nil)
+	require.NoError(t, err)
+	resp, err := NewStandardClient(Timeout(50 * time.Millisecond)).Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	_, err = io.ReadAll(resp.Body)
+	require.Error(t, err)
+}
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	c := NewStandardClient(UnixSocket(path), DNSCache(time.Minute), HTTP2(false))
+	req, err := NewRequest(context.Background(), http.MethodGet, "http://docker/v1/info", nil, nil)
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "/v1/info", string(body))
+}
+
+type countingResolver struct {
+	lookups int32
+}
+
+func (c *countingResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	atomic.AddInt32(&c.lookups, 1)
+	if host != "api.test" {
+		return nil, errors.New("unknown host " + host)
+	}
+	return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, nil
+}
+
+func TestDNSCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	resolver := &countingResolver{}
+	c := NewStandardClient(DNSCache(200*time.Millisecond), DisableKeepAlives(), func(o *option) {
+		o.lookup = resolver
+	})
+	get := func() {
+		req, err := NewRequest(context.Background(), http.MethodGet, "http://api.test:"+u.Port(), nil, nil)
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	for i := 0; i < 3; i++ {
+		get()
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&resolver.lookups))
+	time.Sleep(300 * time.Millisecond)
+	get()
+	get()
+	require.Equal(t, int32(2), atomic.LoadInt32(&resolver.lookups))
+}
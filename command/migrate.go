@@ -0,0 +1,99 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/crochee/lirity/db"
+)
+
+// NewMigrate returns migrate command with up, down and status subcommands,
+// newMigrator is called with the options of the flags, e.g.
+//
+//	command.NewMigrate(func(ctx context.Context, opts ...func(*db.MigrateOption)) (*db.Migrator, error) {
+//		client, err := db.New(ctx, ...)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return db.NewMigrator(client, migrations, opts...)
+//	})
+func NewMigrate(
+	newMigrator func(ctx context.Context, opts ...func(*db.MigrateOption)) (*db.Migrator, error)) *cobra.Command {
+	var dryRun bool
+	migrator := func(cmd *cobra.Command) (*db.Migrator, error) {
+		return newMigrator(cmd.Context(), func(o *db.MigrateOption) {
+			o.DryRun = dryRun
+			o.Output = cmd.OutOrStdout()
+		})
+	}
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate database schema",
+	}
+	cmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print sql instead of running it")
+
+	var to int64
+	up := &cobra.Command{
+		Use:   "up",
+		Short: "Apply pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := migrator(cmd)
+			if err != nil {
+				return err
+			}
+			return m.Up(cmd.Context(), to)
+		},
+	}
+	up.Flags().Int64Var(&to, "to", 0, "apply migrations up to the version, 0 means all")
+
+	var steps int
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "Revert applied migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := migrator(cmd)
+			if err != nil {
+				return err
+			}
+			return m.Down(cmd.Context(), steps)
+		},
+	}
+	down.Flags().IntVar(&steps, "steps", 1, "number of migrations to revert")
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "Show migration status",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := migrator(cmd)
+			if err != nil {
+				return err
+			}
+			var list []*db.MigrationStatus
+			if list, err = m.Status(cmd.Context()); err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+			for _, s := range list {
+				applied := "pending"
+				if !s.AppliedAt.IsZero() {
+					applied = s.AppliedAt.Format(time.RFC3339)
+				}
+				if s.Missing {
+					applied += " (missing)"
+				}
+				_, _ = fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.AddCommand(up, down, status)
+	return cmd
+}
@@ -28,8 +28,10 @@ type Base struct {
 	Time
 }
 
+// Time is filled by gorm on create and update so that it works on every driver,
+// mysql tables also get the db level defaults of mysqlDefault
 type Time struct {
-	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;not null;default:current_timestamp();comment:创建时间"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at;not null;default:current_timestamp() on update current_timestamp();comment:更新时间"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;not null;autoCreateTime;mysqlDefault:current_timestamp();comment:创建时间"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at;not null;autoUpdateTime;mysqlDefault:current_timestamp() on update current_timestamp();comment:更新时间"`
 	DeletedAt DeletedAt `json:"-" gorm:"column:deleted_at;index;comment:删除时间"`
 }
@@ -0,0 +1,313 @@
+package db
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/crochee/lirity/e"
+	"github.com/crochee/lirity/variable"
+)
+
+// ErrInvalidCursor is returned when the cursor token is malformed, forged or of another sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+var cursorSecret atomic.Value
+
+func init() {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	cursorSecret.Store(secret)
+}
+
+// SetCursorSecret sets the key signing cursor tokens, the default key is random per process
+// so every instance of a service must set the same key
+func SetCursorSecret(secret []byte) {
+	cursorSecret.Store(append([]byte(nil), secret...))
+}
+
+// Cursor keyset pagination, rows are located by the sort key values of the last row instead of offset.
+// Key is appended to the sort keys to make them unique, the sort keys must not be null.
+type Cursor struct {
+	Sort
+	// Token is Next or Prev of the previous page, empty means the first page
+	Token string `form:"cursor" json:"-"`
+	Size  int    `form:"size" json:"size" binding:"omitempty,min=1"`
+	// Key unique column, default id
+	Key string `form:"-" json:"-"`
+	// WithTotal runs count query for Total
+	WithTotal bool  `form:"with_total" json:"-"`
+	Total     int64 `json:"total,omitempty"`
+
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+
+	orders   []SortOrder
+	backward bool
+}
+
+type cursorToken struct {
+	Sort     string     `json:"s"`
+	Backward bool       `json:"b,omitempty"`
+	Values   [][]string `json:"v"`
+}
+
+// Build adds the keyset conditions, order and limit of Size+1 to query,
+// the extra row tells whether there is a next page and is removed by Find
+func (c *Cursor) Build(_ context.Context, query *gorm.DB, _ ...BuilderOption) *gorm.DB {
+	if c.Size <= 0 {
+		c.Size = variable.DefaultPageSize
+	}
+	if c.Key == "" {
+		c.Key = "id"
+	}
+	var err error
+	if c.orders, err = c.sortOrders(); err != nil {
+		_ = query.AddError(err)
+		return query
+	}
+	if c.WithTotal {
+		query.Session(&gorm.Session{}).Count(&c.Total)
+	}
+	if c.Token != "" {
+		var token *cursorToken
+		if token, err = c.decode(c.Token); err != nil {
+			_ = query.AddError(err)
+			return query
+		}
+		c.backward = token.Backward
+		values := make([]interface{}, len(token.Values))
+		for i, v := range token.Values {
+			if values[i], err = decodeValue(v); err != nil {
+				_ = query.AddError(err)
+				return query
+			}
+		}
+		query = query.Where(c.condition(values))
+	}
+	for _, o := range c.orders {
+		query = query.Order(clause.OrderByColumn{Column: o.column(), Desc: o.Desc != c.backward})
+	}
+	return query.Limit(c.Size + 1)
+}
+
+// Find builds query, finds the page into dest which is a pointer to slice and sets Next and Prev
+func (c *Cursor) Find(ctx context.Context, query *gorm.DB, dest interface{}) error {
+	result := c.Build(ctx, query).Find(dest)
+	if result.Error != nil {
+		return result.Error
+	}
+	rows := reflect.Indirect(reflect.ValueOf(dest))
+	more := rows.Len() > c.Size
+	if more {
+		rows.Set(rows.Slice(0, c.Size))
+	}
+	if c.backward {
+		swap := reflect.Swapper(rows.Interface())
+		for i, j := 0, rows.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+	c.Next, c.Prev = "", ""
+	if rows.Len() == 0 {
+		return nil
+	}
+	var err error
+	// 向前翻页时一定存在下一页，向后翻页时一定存在上一页
+	if more || (c.backward && c.Token != "") {
+		if c.Next, err = c.encode(result.Statement, rows.Index(rows.Len()-1), false); err != nil {
+			return err
+		}
+	}
+	if (more && c.backward) || (!c.backward && c.Token != "") {
+		if c.Prev, err = c.encode(result.Statement, rows.Index(0), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortOrders parses Sort with Key appended, nulls are not supported
+func (c *Cursor) sortOrders() ([]SortOrder, error) {
+	orders, err := c.Sort.Parse()
+	if err != nil {
+		return nil, err
+	}
+	hasKey := false
+	for _, o := range orders {
+		if o.Nulls != "" {
+			return nil, e.ErrInvalidParam.WithResult("cursor doesn't support nulls first or last")
+		}
+		if o.Column == c.Key {
+			hasKey = true
+		}
+	}
+	if !hasKey {
+		orders = append(orders, SortOrder{Column: c.Key, Desc: len(orders) > 0 && orders[len(orders)-1].Desc})
+	}
+	return orders, nil
+}
+
+func (c *Cursor) sortKey() string {
+	keys := make([]string, len(c.orders))
+	for i, o := range c.orders {
+		keys[i] = o.Column
+		if o.Table != "" {
+			keys[i] = o.Table + "." + keys[i]
+		}
+		if o.Desc {
+			keys[i] += " desc"
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+// condition is (a > ?) or (a = ? and b > ?) ..., the operator depends on direction
+func (c *Cursor) condition(values []interface{}) clause.Expression {
+	ors := make([]clause.Expression, 0, len(c.orders))
+	for i, o := range c.orders {
+		ands := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, clause.Eq{Column: c.orders[j].column(), Value: values[j]})
+		}
+		column := o.column()
+		if o.Desc != c.backward {
+			ands = append(ands, clause.Lt{Column: column, Value: values[i]})
+		} else {
+			ands = append(ands, clause.Gt{Column: column, Value: values[i]})
+		}
+		ors = append(ors, clause.And(ands...))
+	}
+	return clause.Or(ors...)
+}
+
+func (c *Cursor) encode(stmt *gorm.Statement, row reflect.Value, backward bool) (string, error) {
+	row = reflect.Indirect(row)
+	token := cursorToken{Sort: c.sortKey(), Backward: backward, Values: make([][]string, len(c.orders))}
+	for i, o := range c.orders {
+		field := stmt.Schema.LookUpField(o.Column)
+		if field == nil {
+			return "", fmt.Errorf("sort column %s is not a field of %s", o.Column, stmt.Schema.Name)
+		}
+		value, _ := field.ValueOf(row)
+		var err error
+		if token.Values[i], err = encodeValue(value); err != nil {
+			return "", err
+		}
+	}
+	payload, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(&token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(sign(payload)), nil
+}
+
+func (c *Cursor) decode(raw string) (*cursorToken, error) {
+	index := strings.IndexByte(raw, '.')
+	if index < 0 {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(raw[:index])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var signature []byte
+	if signature, err = base64.RawURLEncoding.DecodeString(raw[index+1:]); err != nil ||
+		!hmac.Equal(signature, sign(payload)) {
+		return nil, ErrInvalidCursor
+	}
+	var token cursorToken
+	if err = jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(payload, &token); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if token.Sort != c.sortKey() || len(token.Values) != len(c.orders) {
+		return nil, ErrInvalidCursor
+	}
+	return &token, nil
+}
+
+func sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, cursorSecret.Load().([]byte))
+	_, _ = mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// encodeValue keeps the type of value so that it is compared as the same type in sql
+func encodeValue(value interface{}) ([]string, error) {
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return nil, err
+		}
+		value = v
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return []string{"t", t.Format(time.RFC3339Nano)}, nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return []string{"s", v.String()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return []string{"i", strconv.FormatInt(v.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return []string{"u", strconv.FormatUint(v.Uint(), 10)}, nil
+	case reflect.Float32, reflect.Float64:
+		return []string{"f", strconv.FormatFloat(v.Float(), 'g', -1, 64)}, nil
+	case reflect.Bool:
+		return []string{"b", strconv.FormatBool(v.Bool())}, nil
+	case reflect.Slice:
+		if b, ok := v.Interface().([]byte); ok {
+			return []string{"s", string(b)}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported cursor value %T", value)
+}
+
+func decodeValue(v []string) (interface{}, error) {
+	if len(v) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	var (
+		value interface{}
+		err   error
+	)
+	switch v[0] {
+	case "t":
+		value, err = time.Parse(time.RFC3339Nano, v[1])
+	case "s":
+		value = v[1]
+	case "i":
+		value, err = strconv.ParseInt(v[1], 10, 64)
+	case "u":
+		value, err = strconv.ParseUint(v[1], 10, 64)
+	case "f":
+		value, err = strconv.ParseFloat(v[1], 64)
+	case "b":
+		value, err = strconv.ParseBool(v[1])
+	default:
+		return nil, ErrInvalidCursor
+	}
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return value, nil
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type cursorUser struct {
+	ID        uint `gorm:"primaryKey"`
+	Name      string
+	CreatedAt time.Time
+}
+
+func TestCursor(t *testing.T) {
+	client, err := New(context.Background(), func(o *Option) { o.Driver = DriverSQLite })
+	require.NoError(t, err)
+	defer client.Close()
+	require.NoError(t, client.AutoMigrate(&cursorUser{}))
+	base := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 7; i++ {
+		// 每两条的创建时间相同，依赖id区分
+		require.NoError(t, client.Create(&cursorUser{
+			ID:        uint(i),
+			Name:      string(rune('a' + i - 1)),
+			CreatedAt: base.Add(time.Duration(i/2) * time.Hour),
+		}).Error)
+	}
+	ctx := context.Background()
+	page := func(token string, withTotal bool) (*Cursor, []uint) {
+		c := &Cursor{Sort: Sort{SortField: "created_at desc"}, Size: 3, Token: token, WithTotal: withTotal}
+		var users []*cursorUser
+		require.NoError(t, c.Find(ctx, client.With(ctx).Model(&cursorUser{}), &users))
+		ids := make([]uint, len(users))
+		for i, u := range users {
+			ids[i] = u.ID
+		}
+		return c, ids
+	}
+	first, ids := page("", true)
+	require.Equal(t, []uint{7, 6, 5}, ids)
+	require.Equal(t, int64(7), first.Total)
+	require.Empty(t, first.Prev)
+	require.NotEmpty(t, first.Next)
+
+	second, ids := page(first.Next, false)
+	require.Equal(t, []uint{4, 3, 2}, ids)
+	require.Zero(t, second.Total)
+
+	third, ids := page(second.Next, false)
+	require.Equal(t, []uint{1}, ids)
+	require.Empty(t, third.Next)
+
+	back, ids := page(third.Prev, false)
+	require.Equal(t, []uint{4, 3, 2}, ids)
+	back, ids = page(back.Prev, false)
+	require.Equal(t, []uint{7, 6, 5}, ids)
+	require.Empty(t, back.Prev)
+	require.Equal(t, second.Next, func() string { c, _ := page(back.Next, false); return c.Next }())
+
+	// 篡改或排序不一致的游标无效
+	c := &Cursor{Sort: Sort{SortField: "created_at asc"}, Size: 3, Token: first.Next}
+	var users []cursorUser
+	require.ErrorIs(t, c.Find(ctx, client.With(ctx).Model(&cursorUser{}), &users), ErrInvalidCursor)
+	c = &Cursor{Sort: Sort{SortField: "created_at desc"}, Size: 3, Token: first.Next + "x"}
+	require.ErrorIs(t, c.Find(ctx, client.With(ctx).Model(&cursorUser{}), &users), ErrInvalidCursor)
+}
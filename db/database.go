@@ -4,10 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"time"
 
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	glogger "gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
@@ -19,11 +17,14 @@ import (
 var (
 	NotFound           = gorm.ErrRecordNotFound
 	ErrNotRowsAffected = errors.New("0 rows affected")
-	ErrDuplicate       = "1062: Duplicate"
+	// Deprecated: use errors.Is(err, ErrDuplicateKey)
+	ErrDuplicate = "1062: Duplicate"
 )
 
 type Option struct {
 	Debug bool
+	// Driver is DriverMySQL, DriverPostgres or DriverSQLite, default DriverMySQL
+	Driver string
 
 	MaxOpenConn int
 	MaxIdleConn int
@@ -34,11 +35,20 @@ type Option struct {
 	Port     string
 	Database string
 	Charset  string
+	// SSLMode of postgres, default disable
+	SSLMode string
 
 	Timeout         time.Duration
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	ConnMaxLifetime time.Duration
+
+	// Replicas receive reads out of transactions, see WithPrimary
+	Replicas []Replica
+	// ReplicaPolicy default PolicyRandom
+	ReplicaPolicy ReplicaPolicy
+	// EjectDuration a replica is not used after a connection error, default 30s
+	EjectDuration time.Duration
 }
 
 // New with context.Context returns DB
@@ -47,26 +57,49 @@ func New(ctx context.Context, opts ...func(*Option)) (*DB, error) {
 	for _, opt := range opts {
 		opt(&o)
 	}
-	client, err := gorm.Open(mysql.Open(Dsn(&o)),
-		&gorm.Config{
-			PrepareStmt: true,
-			NamingStrategy: schema.NamingStrategy{
-				SingularTable: true, // 不考虑表名单复数变化
-			},
-			DisableForeignKeyConstraintWhenMigrating: true,
-			NowFunc: func() time.Time {
-				return time.Now().UTC()
-			},
-		})
+	client, err := open(&o)
 	if err != nil {
 		return nil, err
 	}
+	if err = registerClassify(client); err != nil {
+		lirity.Close(&DB{DB: client})
+		return nil, err
+	}
+	if err = registerVersion(client); err != nil {
+		lirity.Close(&DB{DB: client})
+		return nil, err
+	}
+	var r *resolver
+	if len(o.Replicas) > 0 {
+		if r, err = newResolver(&o); err != nil {
+			lirity.Close(&DB{DB: client})
+			return nil, err
+		}
+		if err = r.register(client); err != nil {
+			lirity.Close(&DB{DB: client, resolver: r})
+			return nil, err
+		}
+	}
 	session := &gorm.Session{Context: ctx}
 	if o.Debug { // 是否显示sql语句
 		session.Logger = client.Logger.LogMode(glogger.Info)
 	}
-	client = client.Session(session)
+	return &DB{DB: client.Session(session), debug: o.Debug, resolver: r}, nil
+}
 
+func open(o *Option) (*gorm.DB, error) {
+	if o.Driver == DriverSQLite && o.MaxOpenConn == 0 {
+		// sqlite只允许单个写连接，内存数据库随连接关闭而销毁
+		o.MaxOpenConn, o.MaxIdleConn = 1, 1
+	}
+	dialector, err := Dialector(o)
+	if err != nil {
+		return nil, err
+	}
+	var client *gorm.DB
+	if client, err = gorm.Open(dialector, config(true)); err != nil {
+		return nil, err
+	}
 	var sqlDB *sql.DB
 	if sqlDB, err = client.DB(); err != nil {
 		return nil, err
@@ -75,12 +108,13 @@ func New(ctx context.Context, opts ...func(*Option)) (*DB, error) {
 	sqlDB.SetMaxOpenConns(o.MaxOpenConn)        // 默认值0，无限制
 	sqlDB.SetMaxIdleConns(o.MaxIdleConn)        // 默认值2
 	sqlDB.SetConnMaxLifetime(o.ConnMaxLifetime) // 默认值0，永不过期
-	return &DB{DB: client, debug: o.Debug}, nil
+	return client, nil
 }
 
 type DB struct {
 	*gorm.DB
-	debug bool
+	debug    bool
+	resolver *resolver
 }
 
 type SessionOption struct {
@@ -89,7 +123,7 @@ type SessionOption struct {
 	LevelFunc     func(glogger.LogLevel, bool) glogger.LogLevel
 }
 
-// With options to set orm logger
+// With options to set orm logger, the transaction in ctx is used if any
 func (d *DB) With(ctx context.Context, opts ...func(*SessionOption)) *DB {
 	log := logger.From(ctx)
 	o := &SessionOption{
@@ -100,7 +134,12 @@ func (d *DB) With(ctx context.Context, opts ...func(*SessionOption)) *DB {
 	for _, opt := range opts {
 		opt(o)
 	}
-	return &DB{DB: d.Session(&gorm.Session{
+	client := d.DB
+	// 在事务中使用同一个事务
+	if tx, ok := ctx.Value(txKey{}).(*DB); ok {
+		client = tx.DB
+	}
+	return &DB{DB: client.Session(&gorm.Session{
 		Context: ctx,
 		Logger: NewLog(log, glogger.Config{
 			SlowThreshold: o.SlowThreshold,
@@ -108,7 +147,8 @@ func (d *DB) With(ctx context.Context, opts ...func(*SessionOption)) *DB {
 			LogLevel:      o.LevelFunc(glogger.Warn, d.debug),
 		}),
 	}),
-		debug: d.debug,
+		debug:    d.debug,
+		resolver: d.resolver,
 	}
 }
 
@@ -119,6 +159,9 @@ func (d *DB) Close() error {
 		return err
 	}
 	lirity.Close(sqlDB)
+	if d.resolver != nil {
+		d.resolver.close()
+	}
 	return nil
 }
 
@@ -129,17 +172,15 @@ func getLevel(l glogger.LogLevel, debug bool) glogger.LogLevel {
 	return l
 }
 
-func Dsn(opt *Option) string {
-	uri := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=%s&parseTime=%t&loc=%s",
-		opt.User, opt.Password, opt.IP, opt.Port, opt.Database, opt.Charset, true, "UTC")
-	if opt.Timeout != 0 {
-		uri += fmt.Sprintf("&timeout=%s", opt.Timeout)
-	}
-	if opt.ReadTimeout != 0 {
-		uri += fmt.Sprintf("&readTimeout=%s", opt.ReadTimeout)
-	}
-	if opt.ReadTimeout != 0 {
-		uri += fmt.Sprintf("&writeTimeout=%s", opt.ReadTimeout)
+func config(prepareStmt bool) *gorm.Config {
+	return &gorm.Config{
+		PrepareStmt: prepareStmt,
+		NamingStrategy: schema.NamingStrategy{
+			SingularTable: true, // 不考虑表名单复数变化
+		},
+		DisableForeignKeyConstraintWhenMigrating: true,
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
 	}
-	return uri
 }
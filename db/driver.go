@@ -0,0 +1,141 @@
+package db
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Dialector returns the gorm dialector of opt.Driver
+func Dialector(opt *Option) (gorm.Dialector, error) {
+	switch opt.Driver {
+	case "", DriverMySQL:
+		return mysqlDialector{Dialector: &mysql.Dialector{Config: &mysql.Config{DSN: MySQLDsn(opt)}}}, nil
+	case DriverPostgres:
+		return postgres.Open(PostgresDsn(opt)), nil
+	case DriverSQLite:
+		return sqlite.Open(SQLiteDsn(opt)), nil
+	default:
+		return nil, fmt.Errorf("unsupported driver %s", opt.Driver)
+	}
+}
+
+// Dsn returns the dsn of opt.Driver
+func Dsn(opt *Option) string {
+	switch opt.Driver {
+	case DriverPostgres:
+		return PostgresDsn(opt)
+	case DriverSQLite:
+		return SQLiteDsn(opt)
+	default:
+		return MySQLDsn(opt)
+	}
+}
+
+func MySQLDsn(opt *Option) string {
+	uri := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=%s&parseTime=%t&loc=%s",
+		opt.User, opt.Password, opt.IP, opt.Port, opt.Database, opt.Charset, true, "UTC")
+	if opt.Timeout != 0 {
+		uri += fmt.Sprintf("&timeout=%s", opt.Timeout)
+	}
+	if opt.ReadTimeout != 0 {
+		uri += fmt.Sprintf("&readTimeout=%s", opt.ReadTimeout)
+	}
+	if opt.WriteTimeout != 0 {
+		uri += fmt.Sprintf("&writeTimeout=%s", opt.WriteTimeout)
+	}
+	return uri
+}
+
+// PostgresDsn returns key value dsn, ReadTimeout and WriteTimeout are not supported by postgres
+func PostgresDsn(opt *Option) string {
+	sslMode := opt.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	pairs := []string{
+		"host=" + quoteValue(opt.IP),
+		"port=" + quoteValue(opt.Port),
+		"user=" + quoteValue(opt.User),
+		"password=" + quoteValue(opt.Password),
+		"dbname=" + quoteValue(opt.Database),
+		"sslmode=" + sslMode,
+		"TimeZone=UTC",
+	}
+	if opt.Timeout != 0 {
+		// 只支持秒
+		pairs = append(pairs, fmt.Sprintf("connect_timeout=%d", int(opt.Timeout.Seconds())))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// SQLiteDsn returns dsn of the file Database, empty Database means in memory
+func SQLiteDsn(opt *Option) string {
+	database := opt.Database
+	if database == "" {
+		database = ":memory:"
+	}
+	query := url.Values{"_loc": {"UTC"}}
+	if opt.Timeout != 0 {
+		query.Set("_busy_timeout", fmt.Sprint(opt.Timeout.Milliseconds()))
+	}
+	return "file:" + database + "?" + query.Encode()
+}
+
+func quoteValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// mysqlDialector creates tables whose columns have the db level defaults of the mysqlDefault tag,
+// such as on update which other drivers don't support
+type mysqlDialector struct {
+	*mysql.Dialector
+}
+
+func (d mysqlDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return mysqlMigrator{Migrator: d.Dialector.Migrator(db).(mysql.Migrator)}
+}
+
+type mysqlMigrator struct {
+	mysql.Migrator
+}
+
+func (m mysqlMigrator) FullDataTypeOf(field *schema.Field) clause.Expr {
+	expr := m.Migrator.Migrator.FullDataTypeOf(field)
+	if value, ok := field.TagSettings["MYSQLDEFAULT"]; ok && !field.HasDefaultValue {
+		expr.SQL += " DEFAULT " + value
+	}
+	if value, ok := field.TagSettings["COMMENT"]; ok {
+		expr.SQL += " COMMENT " + m.Dialector.Explain("?", value)
+	}
+	return expr
+}
+
+// AlterColumn mysql.Migrator modifies column by its own FullDataTypeOf
+func (m mysqlMigrator) AlterColumn(value interface{}, field string) error {
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		if field := stmt.Schema.LookUpField(field); field != nil {
+			return m.DB.Exec(
+				"ALTER TABLE ? MODIFY COLUMN ? ?",
+				clause.Table{Name: stmt.Table}, clause.Column{Name: field.DBName}, m.FullDataTypeOf(field),
+			).Error
+		}
+		return fmt.Errorf("failed to look up field with name: %s", field)
+	})
+}
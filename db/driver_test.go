@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type driverUser struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"size:64"`
+	Time
+}
+
+func TestDsn(t *testing.T) {
+	o := &Option{
+		User:         "root",
+		Password:     "p w'd",
+		IP:           "127.0.0.1",
+		Port:         "5432",
+		Database:     "test",
+		Charset:      "utf8mb4",
+		Timeout:      5 * time.Second,
+		WriteTimeout: time.Second,
+	}
+	require.Equal(t, "root:p w'd@tcp(127.0.0.1:5432)/test?charset=utf8mb4&parseTime=true&loc=UTC"+
+		"&timeout=5s&writeTimeout=1s", Dsn(o))
+	o.Driver = DriverPostgres
+	require.Equal(t, `host=127.0.0.1 port=5432 user=root password='p w\'d' dbname=test sslmode=disable `+
+		"TimeZone=UTC connect_timeout=5", Dsn(o))
+	o.Driver = DriverSQLite
+	require.Equal(t, "file:test?_busy_timeout=5000&_loc=UTC", Dsn(o))
+	o.Driver = "oracle"
+	_, err := Dialector(o)
+	require.Error(t, err)
+}
+
+func TestSQLite(t *testing.T) {
+	client, err := New(context.Background(), func(o *Option) { o.Driver = DriverSQLite })
+	require.NoError(t, err)
+	defer client.Close()
+	require.NoError(t, client.AutoMigrate(&driverUser{}))
+
+	user := &driverUser{Name: "a"}
+	require.NoError(t, client.Create(user).Error)
+	require.False(t, user.CreatedAt.IsZero())
+	created := user.UpdatedAt
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, client.Model(user).Update("name", "b").Error)
+
+	var found driverUser
+	require.NoError(t, client.First(&found, user.ID).Error)
+	require.Equal(t, "b", found.Name)
+	require.True(t, found.UpdatedAt.After(created))
+
+	require.NoError(t, client.Delete(&found).Error)
+	require.ErrorIs(t, client.First(&driverUser{}, user.ID).Error, NotFound)
+}
+
+func TestMock(t *testing.T) {
+	for driver, query := range map[string]string{
+		DriverMySQL:    "SELECT * FROM `driver_user` WHERE `driver_user`.`id` = ?",
+		DriverPostgres: `SELECT * FROM "driver_user" WHERE "driver_user"."id" = $1`,
+		DriverSQLite:   "SELECT * FROM `driver_user` WHERE `driver_user`.`id` = ?",
+	} {
+		driver := driver
+		mock, err := Mock(func(o *Option) { o.Driver = driver })
+		require.NoError(t, err)
+		mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "a"))
+		var user driverUser
+		require.NoError(t, With(context.Background()).Take(&user, 1).Error, driver)
+		require.Equal(t, "a", user.Name)
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestMySQLDefault(t *testing.T) {
+	for driver, expected := range map[string]string{
+		DriverMySQL:    "datetime(3) NOT NULL DEFAULT current_timestamp() on update current_timestamp() COMMENT '更新时间'",
+		DriverPostgres: "timestamptz NOT NULL",
+	} {
+		_, err := Mock(func(o *Option) { o.Driver = driver })
+		require.NoError(t, err)
+		client := With(context.Background())
+		stmt := &gorm.Statement{DB: client.DB}
+		require.NoError(t, stmt.Parse(&driverUser{}))
+		expr := client.Migrator().FullDataTypeOf(stmt.Schema.LookUpField("updated_at"))
+		require.Equal(t, expected, expr.SQL, driver)
+	}
+}
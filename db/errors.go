@@ -0,0 +1,160 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+
+	"github.com/crochee/lirity/e"
+)
+
+var (
+	ErrDuplicateKey = errors.New("duplicate key")
+	ErrForeignKey   = errors.New("foreign key violation")
+	ErrDeadlock     = errors.New("deadlock")
+	ErrLockTimeout  = errors.New("lock wait timeout")
+	ErrConnLost     = errors.New("connection lost")
+	ErrDataTooLong  = errors.New("data too long")
+)
+
+// Error is a driver error classified as Kind, errors.Is(err, Kind) and errors.As the driver error both work
+type Error struct {
+	Kind error
+	// Key is the index or constraint name of ErrDuplicateKey and ErrForeignKey if the driver reports it
+	Key string
+	Err error
+}
+
+func (d *Error) Error() string {
+	if d.Key != "" {
+		return fmt.Sprintf("%s %s,%v", d.Kind, d.Key, d.Err)
+	}
+	return fmt.Sprintf("%s,%v", d.Kind, d.Err)
+}
+
+func (d *Error) Unwrap() error {
+	return d.Err
+}
+
+func (d *Error) Is(target error) bool {
+	return d.Kind == target
+}
+
+var (
+	mysqlKey = regexp.MustCompile(`for key '([^']+)'`)
+	mysqlFK  = regexp.MustCompile("CONSTRAINT `([^`]+)`")
+)
+
+// Classify returns *Error of mysql, postgres and sqlite errors, other errors are returned as they are
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+	var (
+		mysqlErr *mysql.MySQLError
+		pgErr    *pgconn.PgError
+	)
+	switch {
+	case errors.As(err, &mysqlErr):
+		switch mysqlErr.Number {
+		case 1062, 1586:
+			return &Error{Kind: ErrDuplicateKey, Key: submatch(mysqlKey, mysqlErr.Message), Err: err}
+		case 1216, 1217, 1451, 1452:
+			return &Error{Kind: ErrForeignKey, Key: submatch(mysqlFK, mysqlErr.Message), Err: err}
+		case 1213:
+			return &Error{Kind: ErrDeadlock, Err: err}
+		case 1205:
+			return &Error{Kind: ErrLockTimeout, Err: err}
+		case 1406:
+			return &Error{Kind: ErrDataTooLong, Err: err}
+		case 2006, 2013:
+			return &Error{Kind: ErrConnLost, Err: err}
+		}
+	case errors.As(err, &pgErr):
+		switch {
+		case pgErr.Code == "23505":
+			return &Error{Kind: ErrDuplicateKey, Key: pgErr.ConstraintName, Err: err}
+		case pgErr.Code == "23503":
+			return &Error{Kind: ErrForeignKey, Key: pgErr.ConstraintName, Err: err}
+		case pgErr.Code == "40P01":
+			return &Error{Kind: ErrDeadlock, Err: err}
+		case pgErr.Code == "55P03":
+			return &Error{Kind: ErrLockTimeout, Err: err}
+		case pgErr.Code == "22001":
+			return &Error{Kind: ErrDataTooLong, Err: err}
+		case strings.HasPrefix(pgErr.Code, "08"), pgErr.Code == "57P01":
+			return &Error{Kind: ErrConnLost, Err: err}
+		}
+	}
+	if sqliteErr := classifySqlite(err); sqliteErr != nil {
+		return sqliteErr
+	}
+	if errors.Is(err, mysql.ErrInvalidConn) || isConnError(err) {
+		return &Error{Kind: ErrConnLost, Err: err}
+	}
+	return err
+}
+
+func submatch(re *regexp.Regexp, s string) string {
+	if m := re.FindStringSubmatch(s); len(m) > 1 {
+		return m[1]
+	}
+	return ""
+}
+
+// ToErrorCode converts err to e.ErrorCode after Classify, unknown errors are e.ErrInternalServerError
+func ToErrorCode(err error) e.ErrorCode {
+	var code e.ErrorCode
+	if errors.As(err, &code) {
+		return code
+	}
+	err = Classify(err)
+	switch {
+	case errors.Is(err, NotFound):
+		return e.ErrNotFound
+	case errors.Is(err, ErrDuplicateKey):
+		var classified *Error
+		errors.As(err, &classified)
+		return e.ErrConflict.WithResult(classified.Key)
+	case errors.Is(err, ErrVersionConflict):
+		return e.ErrConflict.WithResult(ErrVersionConflict.Error())
+	case errors.Is(err, ErrForeignKey), errors.Is(err, ErrDataTooLong):
+		return e.ErrInvalidParam
+	case errors.Is(err, ErrDeadlock), errors.Is(err, ErrLockTimeout), errors.Is(err, ErrConnLost):
+		return e.ErrServiceUnavailable
+	default:
+		return e.ErrInternalServerError
+	}
+}
+
+// registerClassify classifies the errors of every statement
+func registerClassify(client *gorm.DB) error {
+	classify := func(db *gorm.DB) {
+		if db.Error != nil {
+			db.Error = Classify(db.Error)
+		}
+	}
+	callback := client.Callback()
+	for _, register := range []func() error{
+		func() error { return callback.Create().After("gorm:create").Register("lirity:classify", classify) },
+		func() error { return callback.Query().After("gorm:query").Register("lirity:classify", classify) },
+		func() error { return callback.Update().After("gorm:update").Register("lirity:classify", classify) },
+		func() error { return callback.Delete().After("gorm:delete").Register("lirity:classify", classify) },
+		func() error { return callback.Row().After("gorm:row").Register("lirity:classify", classify) },
+		func() error { return callback.Raw().After("gorm:raw").Register("lirity:classify", classify) },
+	} {
+		if err := register(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !cgo
+
+package db
+
+// classifySqlite go-sqlite3 is not available without cgo, so there is no sqlite error to classify
+func classifySqlite(error) error {
+	return nil
+}
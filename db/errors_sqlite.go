@@ -0,0 +1,33 @@
+//go:build cgo
+
+package db
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var sqliteKey = regexp.MustCompile(`constraint failed: (.+)$`)
+
+// classifySqlite returns *Error of sqlite errors, nil when err is not a classified sqlite error
+func classifySqlite(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return &Error{Kind: ErrDuplicateKey, Key: submatch(sqliteKey, sqliteErr.Error()), Err: err}
+	case sqlite3.ErrConstraintForeignKey:
+		return &Error{Kind: ErrForeignKey, Err: err}
+	}
+	switch sqliteErr.Code {
+	case sqlite3.ErrBusy, sqlite3.ErrLocked:
+		return &Error{Kind: ErrLockTimeout, Err: err}
+	case sqlite3.ErrTooBig:
+		return &Error{Kind: ErrDataTooLong, Err: err}
+	}
+	return nil
+}
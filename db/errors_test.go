@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/require"
+
+	"github.com/crochee/lirity/e"
+)
+
+type uniqueUser struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"size:8;uniqueIndex"`
+}
+
+func TestClassify(t *testing.T) {
+	testList := []struct {
+		err  error
+		kind error
+		key  string
+	}{
+		{
+			err:  &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a' for key 'user.idx_name'"},
+			kind: ErrDuplicateKey,
+			key:  "user.idx_name",
+		},
+		{
+			err: &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: " +
+				"a foreign key constraint fails (`test`.`order`, CONSTRAINT `fk_user` FOREIGN KEY (`user_id`))"},
+			kind: ErrForeignKey,
+			key:  "fk_user",
+		},
+		{err: &mysql.MySQLError{Number: 1213}, kind: ErrDeadlock},
+		{err: &mysql.MySQLError{Number: 1205}, kind: ErrLockTimeout},
+		{err: &mysql.MySQLError{Number: 1406}, kind: ErrDataTooLong},
+		{err: mysql.ErrInvalidConn, kind: ErrConnLost},
+		{err: &pgconn.PgError{Code: "23505", ConstraintName: "idx_name"}, kind: ErrDuplicateKey, key: "idx_name"},
+		{err: &pgconn.PgError{Code: "40P01"}, kind: ErrDeadlock},
+		{err: &pgconn.PgError{Code: "08006"}, kind: ErrConnLost},
+	}
+	for _, tc := range testList {
+		err := Classify(fmt.Errorf("query,%w", tc.err))
+		require.ErrorIs(t, err, tc.kind, tc.err.Error())
+		var classified *Error
+		require.True(t, errors.As(err, &classified))
+		require.Equal(t, tc.key, classified.Key)
+		require.ErrorIs(t, err, tc.err)
+	}
+	other := errors.New("other")
+	require.Equal(t, other, Classify(other))
+	require.True(t, IsDeadlock(&pgconn.PgError{Code: "55P03"}))
+}
+
+func TestToErrorCode(t *testing.T) {
+	client, err := New(context.Background(), func(o *Option) { o.Driver = DriverSQLite })
+	require.NoError(t, err)
+	defer client.Close()
+	require.NoError(t, client.AutoMigrate(&uniqueUser{}))
+	ctx := context.Background()
+	require.NoError(t, client.With(ctx).Create(&uniqueUser{Name: "a"}).Error)
+
+	err = client.With(ctx).Create(&uniqueUser{Name: "a"}).Error
+	require.ErrorIs(t, err, ErrDuplicateKey)
+	code := ToErrorCode(err)
+	require.Equal(t, http.StatusConflict, code.StatusCode())
+	require.Equal(t, "unique_user.name", code.Result())
+
+	err = client.With(ctx).First(&uniqueUser{}, 100).Error
+	require.Equal(t, e.ErrNotFound, ToErrorCode(err))
+	require.Equal(t, http.StatusServiceUnavailable, ToErrorCode(&mysql.MySQLError{Number: 1213}).StatusCode())
+	require.Equal(t, e.ErrInvalidParam, ToErrorCode(&mysql.MySQLError{Number: 1406}))
+	require.Equal(t, e.ErrInternalServerError, ToErrorCode(errors.New("other")))
+}
@@ -0,0 +1,253 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/crochee/lirity/e"
+)
+
+// Filter builds where conditions from the fields of Value tagged with filter, e.g.
+//
+//	type ListUser struct {
+//		Name    string      `form:"name" filter:"name,op=like"`
+//		Status  []int       `form:"status" filter:"status,op=in"`
+//		Created []time.Time `form:"created" filter:"created_at,op=range"`
+//		Deleted *bool       `form:"deleted" filter:"deleted_at,op=null"`
+//		Env     string      `form:"env" filter:"labels->env"`
+//		Keyword struct {
+//			Name string `form:"keyword" filter:"name,op=like"`
+//			Desc string `form:"keyword" filter:"description,op=like"`
+//		} `filter:",group=or"`
+//	}
+//
+// The tag is "column[,op=eq|ne|gt|gte|lt|lte|in|nin|like|range|null]", column may be qualified as table.column
+// or be a json path as column->key.key. A struct field tagged ",group=and|or" is a nested group,
+// an embedded struct without tag is flattened. Zero fields are skipped, use pointers to filter by zero values.
+// range takes a slice of [min, max] whose zero ends are unbounded, null takes a bool meaning is null or not.
+type Filter struct {
+	Value interface{}
+	// Fields whitelist maps tag column to column as Sort.Fields, nil means tag columns are columns
+	Fields map[string]string
+}
+
+func (f *Filter) Build(_ context.Context, query *gorm.DB, _ ...BuilderOption) *gorm.DB {
+	expression, err := f.Parse(query)
+	if err != nil {
+		_ = query.AddError(err)
+		return query
+	}
+	if expression == nil {
+		return query
+	}
+	return query.Where(expression)
+}
+
+// Parse returns the conditions of Value, nil when there is no condition.
+// query decides how to quote columns and extract json paths.
+func (f *Filter) Parse(query *gorm.DB) (clause.Expression, error) {
+	value := reflect.ValueOf(f.Value)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("filter value %T is not a struct", f.Value)
+	}
+	return f.group(query, value, false)
+}
+
+func (f *Filter) group(query *gorm.DB, value reflect.Value, or bool) (clause.Expression, error) {
+	exprs := make([]clause.Expression, 0, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag, ok := field.Tag.Lookup("filter")
+		if !ok && field.Anonymous {
+			// 嵌入的结构体展开到当前分组
+			embedded := reflect.Indirect(value.Field(i))
+			if embedded.Kind() != reflect.Struct {
+				continue
+			}
+			expr, err := f.group(query, embedded, or)
+			if err != nil {
+				return nil, err
+			}
+			if expr != nil {
+				exprs = append(exprs, expr)
+			}
+			continue
+		}
+		if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, options := parseFilterTag(tag)
+		fieldValue := value.Field(i)
+		if group, ok := options["group"]; ok {
+			if group != "and" && group != "or" {
+				return nil, fmt.Errorf("invalid filter group %s of %s", group, field.Name)
+			}
+			fieldValue = reflect.Indirect(fieldValue)
+			if fieldValue.Kind() != reflect.Struct {
+				continue
+			}
+			expr, err := f.group(query, fieldValue, group == "or")
+			if err != nil {
+				return nil, err
+			}
+			if expr != nil {
+				exprs = append(exprs, expr)
+			}
+			continue
+		}
+		if fieldValue.IsZero() {
+			continue
+		}
+		if name == "" {
+			name = query.NamingStrategy.ColumnName("", field.Name)
+		}
+		column, err := f.column(query, name)
+		if err != nil {
+			return nil, err
+		}
+		var expr clause.Expression
+		if expr, err = condition(column, options["op"], reflect.Indirect(fieldValue)); err != nil {
+			return nil, fmt.Errorf("filter %s,%w", field.Name, err)
+		}
+		if expr != nil {
+			exprs = append(exprs, expr)
+		}
+	}
+	switch {
+	case len(exprs) == 0:
+		return nil, nil
+	case len(exprs) == 1:
+		// 单个条件的OR会被gorm拼接为 OR
+		return exprs[0], nil
+	case or:
+		return clause.Or(exprs...), nil
+	default:
+		return clause.And(exprs...), nil
+	}
+}
+
+func parseFilterTag(tag string) (string, map[string]string) {
+	parts := strings.Split(tag, ",")
+	options := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		key, value := part, ""
+		if index := strings.IndexByte(part, '='); index >= 0 {
+			key, value = part[:index], part[index+1:]
+		}
+		options[strings.TrimSpace(key)] = strings.ToLower(strings.TrimSpace(value))
+	}
+	return strings.TrimSpace(parts[0]), options
+}
+
+// column returns the quoted column, a json path is extracted as text by the dialect of query
+func (f *Filter) column(query *gorm.DB, name string) (clause.Column, error) {
+	column := name
+	if f.Fields != nil {
+		var ok bool
+		if column, ok = f.Fields[name]; !ok {
+			return clause.Column{}, e.ErrInvalidParam.WithResult(fmt.Sprintf("unknown filter field %s", name))
+		}
+	}
+	var path []string
+	if index := strings.Index(column, "->"); index >= 0 {
+		column, path = column[:index], strings.Split(column[index+2:], ".")
+		for _, key := range path {
+			if !identifier.MatchString(key) {
+				return clause.Column{}, e.ErrInvalidParam.WithResult(fmt.Sprintf("invalid filter field %s", name))
+			}
+		}
+	}
+	if !identifier.MatchString(column) {
+		return clause.Column{}, e.ErrInvalidParam.WithResult(fmt.Sprintf("invalid filter field %s", name))
+	}
+	result := clause.Column{Name: column}
+	if index := strings.IndexByte(column, '.'); index >= 0 {
+		result.Table, result.Name = column[:index], column[index+1:]
+	}
+	if len(path) == 0 {
+		return result, nil
+	}
+	quoted := query.Statement.Quote(result)
+	switch query.Dialector.Name() {
+	case DriverPostgres:
+		quoted = fmt.Sprintf("%s #>> '{%s}'", quoted, strings.Join(path, ","))
+	case DriverSQLite:
+		quoted = fmt.Sprintf("JSON_EXTRACT(%s, '$.%s')", quoted, strings.Join(path, "."))
+	default:
+		quoted = fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, '$.%s'))", quoted, strings.Join(path, "."))
+	}
+	return clause.Column{Name: quoted, Raw: true}, nil
+}
+
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func condition(column clause.Column, op string, value reflect.Value) (clause.Expression, error) {
+	switch op {
+	case "", "eq":
+		return clause.Eq{Column: column, Value: value.Interface()}, nil
+	case "ne":
+		return clause.Neq{Column: column, Value: value.Interface()}, nil
+	case "gt":
+		return clause.Gt{Column: column, Value: value.Interface()}, nil
+	case "gte":
+		return clause.Gte{Column: column, Value: value.Interface()}, nil
+	case "lt":
+		return clause.Lt{Column: column, Value: value.Interface()}, nil
+	case "lte":
+		return clause.Lte{Column: column, Value: value.Interface()}, nil
+	case "in", "nin":
+		if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+			return nil, fmt.Errorf("op %s requires slice but got %s", op, value.Type())
+		}
+		values := make([]interface{}, value.Len())
+		for i := range values {
+			values[i] = value.Index(i).Interface()
+		}
+		if op == "nin" {
+			return clause.Not(clause.IN{Column: column, Values: values}), nil
+		}
+		return clause.IN{Column: column, Values: values}, nil
+	case "like":
+		if value.Kind() != reflect.String {
+			return nil, fmt.Errorf("op like requires string but got %s", value.Type())
+		}
+		// 转义通配符，按包含匹配
+		return clause.Expr{
+			SQL:  "? LIKE ? ESCAPE '!'",
+			Vars: []interface{}{column, "%" + likeEscaper.Replace(value.String()) + "%"},
+		}, nil
+	case "range":
+		if (value.Kind() != reflect.Slice && value.Kind() != reflect.Array) || value.Len() > 2 {
+			return nil, fmt.Errorf("op range requires [min, max] but got %s", value.Type())
+		}
+		exprs := make([]clause.Expression, 0, 2)
+		if value.Len() > 0 && !value.Index(0).IsZero() {
+			exprs = append(exprs, clause.Gte{Column: column, Value: value.Index(0).Interface()})
+		}
+		if value.Len() > 1 && !value.Index(1).IsZero() {
+			exprs = append(exprs, clause.Lte{Column: column, Value: value.Index(1).Interface()})
+		}
+		return clause.And(exprs...), nil
+	case "null":
+		if value.Kind() != reflect.Bool {
+			return nil, fmt.Errorf("op null requires bool but got %s", value.Type())
+		}
+		if value.Bool() {
+			return clause.Eq{Column: column, Value: nil}, nil
+		}
+		return clause.Neq{Column: column, Value: nil}, nil
+	default:
+		return nil, fmt.Errorf("unsupported op %s", op)
+	}
+}
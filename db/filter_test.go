@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/crochee/lirity/e"
+)
+
+type filterUser struct {
+	ID        uint `gorm:"primaryKey"`
+	Name      string
+	Status    int
+	Remark    *string
+	CreatedAt time.Time
+}
+
+type filterPage struct {
+	Status []int `filter:"status,op=in"`
+}
+
+type listFilterUser struct {
+	filterPage
+	Name    string      `filter:"name,op=like"`
+	Status  *int        `filter:"status,op=ne"`
+	Created []time.Time `filter:"created_at,op=range"`
+	Remark  *bool       `filter:"remark,op=null"`
+	Ignored string
+	Keyword struct {
+		Name   string `filter:"name"`
+		Remark string `filter:"remark,op=like"`
+	} `filter:",group=or"`
+}
+
+func TestFilter(t *testing.T) {
+	client, err := New(context.Background(), func(o *Option) { o.Driver = DriverSQLite })
+	require.NoError(t, err)
+	defer client.Close()
+	require.NoError(t, client.AutoMigrate(&filterUser{}))
+	base := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	remark := "50%_off"
+	for i, name := range []string{"alice", "bob", "carol", "dave"} {
+		user := &filterUser{ID: uint(i + 1), Name: name, Status: i % 2, CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if i%2 == 0 {
+			user.Remark = &remark
+		}
+		require.NoError(t, client.Create(user).Error)
+	}
+	find := func(value interface{}) []uint {
+		var users []*filterUser
+		query := (&Filter{Value: value}).Build(context.Background(), client.Model(&filterUser{}))
+		require.NoError(t, query.Order("id").Find(&users).Error)
+		ids := make([]uint, len(users))
+		for i, u := range users {
+			ids[i] = u.ID
+		}
+		return ids
+	}
+	zero, yes, no := 0, true, false
+
+	assert.Equal(t, []uint{1, 2, 3, 4}, find(&listFilterUser{}))
+	assert.Equal(t, []uint{1, 2, 3, 4}, find((*listFilterUser)(nil)))
+	assert.Equal(t, []uint{3}, find(&listFilterUser{Name: "ar"}))
+	assert.Equal(t, []uint{2, 4}, find(&listFilterUser{Status: &zero}))
+	assert.Equal(t, []uint{2, 4}, find(&listFilterUser{filterPage: filterPage{Status: []int{1}}}))
+	assert.Equal(t, []uint{2, 3},
+		find(&listFilterUser{Created: []time.Time{base.Add(time.Hour), base.Add(2 * time.Hour)}}))
+	assert.Equal(t, []uint{3, 4}, find(&listFilterUser{Created: []time.Time{base.Add(2 * time.Hour)}}))
+	assert.Equal(t, []uint{2, 4}, find(&listFilterUser{Remark: &yes}))
+	assert.Equal(t, []uint{1, 3}, find(&listFilterUser{Remark: &no}))
+
+	keyword := &listFilterUser{}
+	keyword.Keyword.Name = "bob"
+	keyword.Keyword.Remark = "%_"
+	// 通配符按字面匹配
+	assert.Equal(t, []uint{1, 2, 3}, find(keyword))
+	keyword.Status = &zero
+	assert.Equal(t, []uint{2}, find(keyword))
+	keyword.Keyword.Remark = "_%"
+	assert.Equal(t, []uint{2}, find(keyword))
+}
+
+func TestFilterFields(t *testing.T) {
+	client, err := New(context.Background(), func(o *Option) { o.Driver = DriverSQLite })
+	require.NoError(t, err)
+	defer client.Close()
+	type query struct {
+		Name  string `filter:"name"`
+		Email string `filter:"email"`
+	}
+	filter := &Filter{Value: &query{Name: "a"}, Fields: map[string]string{"name": "users.name"}}
+	stmt := filter.Build(context.Background(), client.Session(&gorm.Session{DryRun: true}).Table("users")).
+		Find(&[]map[string]interface{}{}).Statement
+	require.NoError(t, stmt.Error)
+	assert.Equal(t, "SELECT * FROM `users` WHERE `users`.`name` = ?", stmt.SQL.String())
+
+	filter.Value = &query{Email: "a"}
+	var code e.ErrorCode
+	err = filter.Build(context.Background(), client.Table("users")).Find(&[]map[string]interface{}{}).Error
+	require.True(t, errors.As(err, &code), "err:%v", err)
+	assert.Equal(t, e.ErrInvalidParam.Code(), code.Code())
+
+	type invalid struct {
+		Name []string `filter:"name,op=like"`
+	}
+	require.Error(t, (&Filter{Value: &invalid{Name: []string{"a"}}}).Build(context.Background(), client.DB).Error)
+}
+
+func TestFilterJSONPath(t *testing.T) {
+	type query struct {
+		Env string `filter:"labels->meta.env"`
+	}
+	tests := []struct {
+		driver string
+		want   string
+	}{
+		{driver: DriverMySQL, want: "SELECT * FROM `users` WHERE JSON_UNQUOTE(JSON_EXTRACT(`labels`, '$.meta.env')) = ?"},
+		{driver: DriverPostgres, want: `SELECT * FROM "users" WHERE "labels" #>> '{meta,env}' = $1`},
+		{driver: DriverSQLite, want: "SELECT * FROM `users` WHERE JSON_EXTRACT(`labels`, '$.meta.env') = ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			_, err := Mock(func(o *Option) { o.Driver = tt.driver })
+			require.NoError(t, err)
+			session := With(context.Background()).Session(&gorm.Session{DryRun: true}).Table("users")
+			stmt := (&Filter{Value: &query{Env: "prod"}}).Build(context.Background(), session).
+				Find(&[]map[string]interface{}{}).Statement
+			require.NoError(t, stmt.Error)
+			assert.Equal(t, tt.want, stmt.SQL.String())
+		})
+	}
+	bad := &Filter{Value: &struct {
+		Env string `filter:"labels->env')"`
+	}{Env: "prod"}}
+	_, err := Mock()
+	require.NoError(t, err)
+	require.Error(t, bad.Build(context.Background(), With(context.Background()).DB).Error)
+}
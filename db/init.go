@@ -2,14 +2,15 @@ package db
 
 import (
 	"context"
+	"fmt"
 	"runtime"
-	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/crochee/lirity"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"gorm.io/gorm/schema"
 )
 
 var c *DB
@@ -28,6 +29,11 @@ func With(ctx context.Context, opts ...func(*SessionOption)) *DB {
 	return c.With(ctx, opts...)
 }
 
+// Transaction call db
+func Transaction(ctx context.Context, fn func(ctx context.Context) error, opts ...func(*TxOption)) error {
+	return c.Transaction(ctx, fn, opts...)
+}
+
 func ClientClose(db *DB) {
 	if db == nil {
 		return
@@ -35,27 +41,40 @@ func ClientClose(db *DB) {
 	lirity.Close(db)
 }
 
-// Mock new a mock 解除测试对数据库等中间件的依赖
-func Mock() (sqlmock.Sqlmock, error) {
+// Mock new a mock 解除测试对数据库等中间件的依赖, Driver of opts selects the sql dialect, default mysql
+func Mock(opts ...func(*Option)) (sqlmock.Sqlmock, error) {
+	var o Option
+	for _, opt := range opts {
+		opt(&o)
+	}
 	// 创建sqlmock
 	slqDB, mock, err := sqlmock.New()
 	if err != nil {
 		return nil, err
 	}
+	var dialector gorm.Dialector
+	switch o.Driver {
+	case "", DriverMySQL:
+		dialector = mysqlDialector{Dialector: &mysql.Dialector{Config: &mysql.Config{
+			SkipInitializeWithVersion: true,
+			Conn:                      slqDB,
+		}}}
+	case DriverPostgres:
+		dialector = postgres.New(postgres.Config{Conn: slqDB})
+	case DriverSQLite:
+		dialector = &sqlite.Dialector{Conn: slqDB}
+	default:
+		return nil, fmt.Errorf("unsupported driver %s", o.Driver)
+	}
 	// 结合gorm、sqlmock
 	var client *gorm.DB
-	if client, err = gorm.Open(mysql.New(mysql.Config{
-		SkipInitializeWithVersion: true,
-		Conn:                      slqDB,
-	}), &gorm.Config{
-		NamingStrategy: schema.NamingStrategy{
-			SingularTable: true, // 不考虑表名单复数变化
-		},
-		DisableForeignKeyConstraintWhenMigrating: true,
-		NowFunc: func() time.Time {
-			return time.Now().UTC()
-		},
-	}); err != nil {
+	if client, err = gorm.Open(dialector, config(false)); err != nil {
+		return nil, err
+	}
+	if err = registerClassify(client); err != nil {
+		return nil, err
+	}
+	if err = registerVersion(client); err != nil {
 		return nil, err
 	}
 	c = &DB{DB: client, debug: true}
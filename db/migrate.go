@@ -0,0 +1,428 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/crochee/lirity"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	ErrMigrationLocked  = errors.New("migration is locked by another process")
+)
+
+// Migration is a versioned schema change, either Up or UpSQL is required
+type Migration struct {
+	// Version orders migrations, e.g. 20220101120000
+	Version int64
+	Name    string
+	// Up and Down run in a transaction, use tx instead of other DB
+	Up   func(ctx context.Context, tx *DB) error
+	Down func(ctx context.Context, tx *DB) error
+	// UpSQL and DownSQL are statements separated by ";"
+	UpSQL   string
+	DownSQL string
+}
+
+// Checksum of Name and UpSQL, an applied migration must not be changed
+func (m *Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Name + "\n" + m.UpSQL))
+	return hex.EncodeToString(sum[:])
+}
+
+type migrationRecord struct {
+	Version   int64     `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;size:255;not null"`
+	Checksum  string    `gorm:"column:checksum;size:64;not null"`
+	AppliedAt time.Time `gorm:"column:applied_at;not null"`
+}
+
+// MigrationStatus of a migration, AppliedAt zero means pending
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+	// Missing is applied but not in the migrations
+	Missing bool
+}
+
+type MigrateOption struct {
+	// Table of the history, default schema_migration
+	Table string
+	// DryRun prints the sql of the pending migrations to Output instead of running them
+	DryRun bool
+	// Output default os.Stdout
+	Output io.Writer
+	// LockTimeout waits for the migration lock held by another process, default 1m
+	LockTimeout time.Duration
+}
+
+// Migrator runs migrations in order and records them in the history table
+type Migrator struct {
+	db         *DB
+	migrations []*Migration
+	o          MigrateOption
+}
+
+// NewMigrator returns Migrator, migrations are sorted by Version which must be unique
+func NewMigrator(db *DB, migrations []*Migration, opts ...func(*MigrateOption)) (*Migrator, error) {
+	o := MigrateOption{
+		Table:       "schema_migration",
+		Output:      os.Stdout,
+		LockTimeout: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	sorted := make([]*Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Up == nil && m.UpSQL == "" {
+			return nil, fmt.Errorf("migration %d %s has no up", m.Version, m.Name)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+	}
+	return &Migrator{db: db, migrations: sorted, o: o}, nil
+}
+
+// Up applies the pending migrations whose Version is not greater than to, to 0 means all
+func (m *Migrator) Up(ctx context.Context, to int64) error {
+	return m.run(ctx, func(applied map[int64]*migrationRecord) error {
+		for _, migration := range m.migrations {
+			if to > 0 && migration.Version > to {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, migration, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down reverts the last steps applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.run(ctx, func(applied map[int64]*migrationRecord) error {
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == nil && migration.DownSQL == "" {
+				return fmt.Errorf("migration %d %s has no down", migration.Version, migration.Name)
+			}
+			if err := m.apply(ctx, migration, false); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status returns the status of migrations ordered by Version
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	if err := m.createTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := &MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		list = append(list, status)
+	}
+	for _, record := range applied {
+		list = append(list, &MigrationStatus{
+			Version:   record.Version,
+			Name:      record.Name,
+			AppliedAt: record.AppliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// run holds the lock and verifies the checksums of applied migrations before fn
+func (m *Migrator) run(ctx context.Context, fn func(applied map[int64]*migrationRecord) error) error {
+	if !m.o.DryRun {
+		unlock, err := m.lock(ctx)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if err = m.createTable(ctx); err != nil {
+			return err
+		}
+	} else if !m.unprepared(ctx).Migrator().HasTable(m.o.Table) {
+		// 空库上预览全部迁移
+		return fn(map[int64]*migrationRecord{})
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	for _, migration := range m.migrations {
+		if record, ok := applied[migration.Version]; ok && record.Checksum != migration.Checksum() {
+			return fmt.Errorf("%w,%d %s", ErrChecksumMismatch, migration.Version, migration.Name)
+		}
+	}
+	return fn(applied)
+}
+
+// unprepared returns DB on the primary without the prepared statement cache which doesn't suit DDL,
+// the history read from a lagging replica would apply migrations again
+func (m *Migrator) unprepared(ctx context.Context) *DB {
+	db := m.db.With(WithPrimary(ctx))
+	if prepared, ok := db.Statement.ConnPool.(*gorm.PreparedStmtDB); ok {
+		db.Statement.ConnPool = prepared.ConnPool
+	}
+	return db
+}
+
+func (m *Migrator) createTable(ctx context.Context) error {
+	return m.unprepared(ctx).Table(m.o.Table).AutoMigrate(&migrationRecord{})
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]*migrationRecord, error) {
+	var records []*migrationRecord
+	if err := m.unprepared(ctx).Table(m.o.Table).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]*migrationRecord, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// apply runs the up or down of migration and updates the history in a transaction,
+// mysql commits DDL implicitly so a failed migration there may be partially applied
+func (m *Migrator) apply(ctx context.Context, migration *Migration, up bool) error {
+	direction, fn, sqlText := "up", migration.Up, migration.UpSQL
+	if !up {
+		direction, fn, sqlText = "down", migration.Down, migration.DownSQL
+	}
+	if m.o.DryRun {
+		return m.print(migration, direction, fn != nil, sqlText)
+	}
+	db := m.unprepared(ctx)
+	err := db.Transaction(ctx, func(ctx context.Context) error {
+		tx := db.With(ctx)
+		if fn != nil {
+			if err := fn(ctx, tx); err != nil {
+				return err
+			}
+		} else {
+			for _, statement := range splitStatements(sqlText) {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if !up {
+			return tx.Table(m.o.Table).Delete(&migrationRecord{}, migration.Version).Error
+		}
+		return tx.Table(m.o.Table).Create(&migrationRecord{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Checksum:  migration.Checksum(),
+			AppliedAt: time.Now().UTC(),
+		}).Error
+	}, func(o *TxOption) { o.Attempts = 1 })
+	if err != nil {
+		return fmt.Errorf("migration %d %s %s failed,%w", migration.Version, migration.Name, direction, err)
+	}
+	return nil
+}
+
+func (m *Migrator) print(migration *Migration, direction string, isFunc bool, sqlText string) error {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "-- %d %s %s\n", migration.Version, migration.Name, direction)
+	if isFunc {
+		// go迁移的语句在运行时才能确定
+		builder.WriteString("-- go migration\n")
+	}
+	for _, statement := range splitStatements(sqlText) {
+		builder.WriteString(statement + ";\n")
+	}
+	_, err := io.WriteString(m.o.Output, builder.String())
+	return err
+}
+
+// lock takes the advisory lock so that only one process migrates,
+// sqlite has no advisory lock and relies on its file lock
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	name := "lirity:" + m.o.Table
+	var lockSQL, unlockSQL string
+	var args []interface{}
+	switch m.db.Dialector.Name() {
+	case DriverMySQL:
+		lockSQL, unlockSQL = "SELECT GET_LOCK(?, 0)", "SELECT RELEASE_LOCK(?)"
+		args = []interface{}{name}
+	case DriverPostgres:
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(name))
+		lockSQL, unlockSQL = "SELECT pg_try_advisory_lock($1)", "SELECT pg_advisory_unlock($1)"
+		args = []interface{}{int64(hash.Sum64())}
+	default:
+		return func() {}, nil
+	}
+	sqlDB, err := m.db.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	// 会话级的锁需要固定连接
+	var conn *sql.Conn
+	if conn, err = sqlDB.Conn(ctx); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(m.o.LockTimeout)
+	for {
+		var locked sql.NullBool
+		if err = conn.QueryRowContext(ctx, lockSQL, args...).Scan(&locked); err != nil {
+			lirity.Close(conn)
+			return nil, err
+		}
+		if locked.Valid && locked.Bool {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			lirity.Close(conn)
+			return nil, ErrMigrationLocked
+		}
+		timer := time.NewTimer(time.Second)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			lirity.Close(conn)
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return func() {
+		// ctx可能已取消，仍需释放锁
+		_, _ = conn.ExecContext(context.Background(), unlockSQL, args...)
+		lirity.Close(conn)
+	}, nil
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadSQLMigrations loads migrations from files named <version>_<name>.up.sql and <version>_<name>.down.sql
+// in the root of fsys, e.g. embed.FS or os.DirFS
+func LoadSQLMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		var version int64
+		if version, err = strconv.ParseInt(match[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid migration file %s,%w", entry.Name(), err)
+		}
+		var content []byte
+		if content, err = fs.ReadFile(fsys, path.Clean(entry.Name())); err != nil {
+			return nil, err
+		}
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			migrations[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has different names %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.UpSQL = string(content)
+		} else {
+			migration.DownSQL = string(content)
+		}
+	}
+	list := make([]*Migration, 0, len(migrations))
+	for _, migration := range migrations {
+		list = append(list, migration)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// splitStatements splits sql by ";" out of quotes and comments,
+// postgres dollar quoted bodies containing ";" should be written as Go migrations
+func splitStatements(sqlText string) []string {
+	var (
+		statements []string
+		start      int
+		quote      byte
+		// 只有注释的语句不执行
+		content bool
+	)
+	for i := 0; i < len(sqlText); i++ {
+		ch := sqlText[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && quote != '`' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote, content = ch, true
+		case ch == '-' && i+1 < len(sqlText) && sqlText[i+1] == '-':
+			for i < len(sqlText) && sqlText[i] != '\n' {
+				i++
+			}
+		case ch == '/' && i+1 < len(sqlText) && sqlText[i+1] == '*':
+			if end := strings.Index(sqlText[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(sqlText)
+			}
+		case ch == ';':
+			if content {
+				statements = append(statements, strings.TrimSpace(sqlText[start:i]))
+			}
+			start, content = i+1, false
+		case ch != ' ' && ch != '\t' && ch != '\r' && ch != '\n':
+			content = true
+		}
+	}
+	if content {
+		statements = append(statements, strings.TrimSpace(sqlText[start:]))
+	}
+	return statements
+}
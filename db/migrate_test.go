@@ -0,0 +1,200 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type migrateUser struct {
+	ID   uint `gorm:"primaryKey"`
+	Name string
+}
+
+func TestMigrator(t *testing.T) {
+	client, err := New(context.Background(), func(o *Option) { o.Driver = DriverSQLite })
+	require.NoError(t, err)
+	defer client.Close()
+	ctx := context.Background()
+	files, err := LoadSQLMigrations(fstest.MapFS{
+		"1_create_account.up.sql": {Data: []byte(`-- 账户
+CREATE TABLE account (id INTEGER PRIMARY KEY, name TEXT DEFAULT 'a;b');
+INSERT INTO account (id, name) VALUES (1, 'x'); /* ; */`)},
+		"1_create_account.down.sql": {Data: []byte("DROP TABLE account;")},
+		"3_add_email.up.sql":        {Data: []byte("ALTER TABLE account ADD COLUMN email TEXT")},
+		"README.md":                 {Data: []byte("ignored")},
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	migrations := append(files, &Migration{
+		Version: 2,
+		Name:    "create_user",
+		Up: func(ctx context.Context, tx *DB) error {
+			return tx.AutoMigrate(&migrateUser{})
+		},
+		Down: func(ctx context.Context, tx *DB) error {
+			return tx.Migrator().DropTable(&migrateUser{})
+		},
+	})
+
+	var output bytes.Buffer
+	dryRun, err := NewMigrator(client, migrations, func(o *MigrateOption) {
+		o.DryRun = true
+		o.Output = &output
+	})
+	require.NoError(t, err)
+	require.NoError(t, dryRun.Up(ctx, 0))
+	assert.Equal(t, `-- 1 create_account up
+-- 账户
+CREATE TABLE account (id INTEGER PRIMARY KEY, name TEXT DEFAULT 'a;b');
+INSERT INTO account (id, name) VALUES (1, 'x');
+-- 2 create_user up
+-- go migration
+-- 3 add_email up
+ALTER TABLE account ADD COLUMN email TEXT;
+`, output.String())
+	assert.False(t, client.Migrator().HasTable("account"))
+
+	migrator, err := NewMigrator(client, migrations)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up(ctx, 2))
+	assert.True(t, client.Migrator().HasTable("account"))
+	assert.True(t, client.Migrator().HasTable(&migrateUser{}))
+	assert.False(t, client.Migrator().HasColumn("account", "email"))
+	status, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 3)
+	assert.False(t, status[1].AppliedAt.IsZero())
+	assert.True(t, status[2].AppliedAt.IsZero())
+
+	require.NoError(t, migrator.Up(ctx, 0))
+	assert.True(t, client.Migrator().HasColumn("account", "email"))
+
+	// 3没有down
+	require.Error(t, migrator.Down(ctx, 1))
+	var account []map[string]interface{}
+	require.NoError(t, client.Table("account").Find(&account).Error)
+	require.Len(t, account, 1)
+
+	migrator, err = NewMigrator(client, []*Migration{migrations[0], migrations[2]})
+	require.NoError(t, err)
+	status, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 3)
+	assert.True(t, status[2].Missing)
+	require.NoError(t, migrator.Down(ctx, 1))
+	assert.False(t, client.Migrator().HasTable(&migrateUser{}))
+	assert.True(t, client.Migrator().HasTable("account"))
+
+	changed := *migrations[0]
+	changed.UpSQL += ";"
+	migrator, err = NewMigrator(client, []*Migration{&changed})
+	require.NoError(t, err)
+	assert.True(t, errors.Is(migrator.Up(ctx, 0), ErrChecksumMismatch))
+
+	_, err = NewMigrator(client, []*Migration{{Version: 1, UpSQL: "SELECT 1"}, {Version: 1, UpSQL: "SELECT 1"}})
+	require.Error(t, err)
+}
+
+func TestMigratorFailed(t *testing.T) {
+	client, err := New(context.Background(), func(o *Option) { o.Driver = DriverSQLite })
+	require.NoError(t, err)
+	defer client.Close()
+	ctx := context.Background()
+	migrator, err := NewMigrator(client, []*Migration{
+		{Version: 1, Name: "ok", UpSQL: "CREATE TABLE a (id INTEGER)"},
+		{Version: 2, Name: "bad", UpSQL: "CREATE TABLE b (id INTEGER); CREATE TABLE a (id INTEGER)"},
+	})
+	require.NoError(t, err)
+	require.Error(t, migrator.Up(ctx, 0))
+	// 失败的迁移整体回滚
+	assert.True(t, client.Migrator().HasTable("a"))
+	assert.False(t, client.Migrator().HasTable("b"))
+	status, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, status[0].AppliedAt.IsZero())
+	assert.True(t, status[1].AppliedAt.IsZero())
+}
+
+func TestMigratorReplica(t *testing.T) {
+	dir := t.TempDir()
+	replica := filepath.Join(dir, "replica.db")
+	client, err := New(context.Background(), func(o *Option) {
+		o.Driver = DriverSQLite
+		o.Database = filepath.Join(dir, "primary.db")
+		o.Replicas = []Replica{{Database: replica}}
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	ctx := context.Background()
+	migrations := []*Migration{{Version: 1, Name: "create_a", UpSQL: "CREATE TABLE a (id INTEGER)"}}
+	// 副本为空库，迁移记录必须从主库读取
+	for i := 0; i < 2; i++ {
+		migrator, err := NewMigrator(client, migrations)
+		require.NoError(t, err)
+		require.NoError(t, migrator.Up(ctx, 0))
+		status, err := migrator.Status(ctx)
+		require.NoError(t, err)
+		require.Len(t, status, 1)
+		assert.False(t, status[0].AppliedAt.IsZero())
+	}
+	var output bytes.Buffer
+	dryRun, err := NewMigrator(client, migrations, func(o *MigrateOption) {
+		o.DryRun = true
+		o.Output = &output
+	})
+	require.NoError(t, err)
+	require.NoError(t, dryRun.Up(ctx, 0))
+	assert.Empty(t, output.String())
+	// 副本上仍然没有迁移的表
+	assert.False(t, client.With(ctx).Migrator().HasTable("a"))
+}
+
+func TestMigratorLock(t *testing.T) {
+	tests := []struct {
+		driver string
+		lock   string
+		unlock string
+	}{
+		{driver: DriverMySQL, lock: "SELECT GET_LOCK(?, 0)", unlock: "SELECT RELEASE_LOCK(?)"},
+		{driver: DriverPostgres, lock: "SELECT pg_try_advisory_lock($1)", unlock: "SELECT pg_advisory_unlock($1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			mock, err := Mock(func(o *Option) { o.Driver = tt.driver })
+			require.NoError(t, err)
+			migrator, err := NewMigrator(With(context.Background()), nil, func(o *MigrateOption) {
+				o.LockTimeout = 0
+			})
+			require.NoError(t, err)
+			mock.ExpectQuery(regexp.QuoteMeta(tt.lock)).
+				WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+			_, err = migrator.lock(context.Background())
+			require.True(t, errors.Is(err, ErrMigrationLocked))
+
+			mock.ExpectQuery(regexp.QuoteMeta(tt.lock)).
+				WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+			mock.ExpectExec(regexp.QuoteMeta(tt.unlock)).WillReturnResult(sqlmock.NewResult(0, 0))
+			unlock, err := migrator.lock(context.Background())
+			require.NoError(t, err)
+			unlock()
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	assert.Equal(t, []string{
+		"INSERT INTO a VALUES ('it''s;', \"x;\", `y;`, 'a\\';b')",
+		"-- 注释;\nUPDATE a SET b = 1",
+	}, splitStatements("INSERT INTO a VALUES ('it''s;', \"x;\", `y;`, 'a\\';b');\n"+
+		"-- 注释;\nUPDATE a SET b = 1;\n-- end"))
+}
@@ -3,10 +3,13 @@ package db
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"github.com/crochee/lirity/e"
 	"github.com/crochee/lirity/variable"
 )
 
@@ -23,24 +26,91 @@ type SQLBuilder interface {
 // Sort 排序
 type Sort struct {
 	// 给多个字段排序 created_at, id asc => order by created_at desc, id asc
+	// 字段后可跟 nulls first 或 nulls last
 	SortField string `form:"sort" json:"sort" binding:"omitempty,order"`
+	// Fields whitelist maps api field to column which may be qualified as table.column,
+	// nil means api fields are column names
+	Fields map[string]string `form:"-" json:"-"`
 }
 
-func (s *Sort) Build(_ context.Context, query *gorm.DB, _ ...BuilderOption) *gorm.DB {
-	// SortField 给多个字段排序
-	// created_at, id asc => order by created_at desc, id asc
-	if s.SortField != "" {
-		sorts := strings.Split(s.SortField, ",")
-		for _, field := range sorts {
-			// 如果排序没有明确要按asc或desc来排序，则按照默认排序(倒序)
-			if !strings.HasSuffix(field, "asc") && !strings.HasSuffix(field, "desc") {
-				query = query.Order(fmt.Sprintf("%s desc", field))
-				continue
+// SortOrder is a parsed field of Sort
+type SortOrder struct {
+	Table  string
+	Column string
+	Desc   bool
+	// Nulls is "", "first" or "last"
+	Nulls string
+}
+
+func (o *SortOrder) column() clause.Column {
+	return clause.Column{Table: o.Table, Name: o.Column}
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Parse returns e.ErrInvalidParam when a field is unknown or has invalid direction
+func (s *Sort) Parse() ([]SortOrder, error) {
+	var orders []SortOrder
+	for _, field := range strings.Split(s.SortField, ",") {
+		tokens := strings.Fields(field)
+		if len(tokens) == 0 {
+			continue
+		}
+		column := tokens[0]
+		if s.Fields != nil {
+			var ok bool
+			if column, ok = s.Fields[tokens[0]]; !ok {
+				return nil, e.ErrInvalidParam.WithResult(fmt.Sprintf("unknown sort field %s", tokens[0]))
 			}
-			query = query.Order(field)
 		}
+		if !identifier.MatchString(column) {
+			return nil, e.ErrInvalidParam.WithResult(fmt.Sprintf("invalid sort field %s", tokens[0]))
+		}
+		// 如果排序没有明确要按asc或desc来排序，则按照默认排序(倒序)
+		order := SortOrder{Column: column, Desc: true}
+		if i := strings.IndexByte(column, '.'); i >= 0 {
+			order.Table, order.Column = column[:i], column[i+1:]
+		}
+		rest := tokens[1:]
+		if len(rest) > 0 {
+			switch strings.ToLower(rest[0]) {
+			case "asc":
+				order.Desc, rest = false, rest[1:]
+			case "desc":
+				rest = rest[1:]
+			}
+		}
+		if len(rest) == 2 && strings.EqualFold(rest[0], "nulls") {
+			order.Nulls, rest = strings.ToLower(rest[1]), nil
+			if order.Nulls != "first" && order.Nulls != "last" {
+				return nil, e.ErrInvalidParam.WithResult(fmt.Sprintf("invalid sort %s", strings.TrimSpace(field)))
+			}
+		}
+		if len(rest) > 0 {
+			return nil, e.ErrInvalidParam.WithResult(fmt.Sprintf("invalid sort %s", strings.TrimSpace(field)))
+		}
+		orders = append(orders, order)
 	}
+	return orders, nil
+}
 
+func (s *Sort) Build(_ context.Context, query *gorm.DB, _ ...BuilderOption) *gorm.DB {
+	orders, err := s.Parse()
+	if err != nil {
+		_ = query.AddError(err)
+		return query
+	}
+	for _, order := range orders {
+		column := order.column()
+		if order.Nulls != "" {
+			// 并非所有数据库都支持NULLS FIRST/LAST，先按是否为NULL排序
+			query = query.Order(clause.OrderByColumn{
+				Column: clause.Column{Name: query.Statement.Quote(column) + " IS NULL", Raw: true},
+				Desc:   order.Nulls == "first",
+			})
+		}
+		query = query.Order(clause.OrderByColumn{Column: column, Desc: order.Desc})
+	}
 	return query
 }
 
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/crochee/lirity/e"
+)
+
+func TestSort(t *testing.T) {
+	client, err := New(context.Background(), func(o *Option) { o.Driver = DriverSQLite })
+	require.NoError(t, err)
+	defer client.Close()
+	fields := map[string]string{"name": "name", "created": "users.created_at"}
+	tests := []struct {
+		name    string
+		sort    Sort
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default_desc",
+			sort: Sort{SortField: "name,id asc"},
+			want: "SELECT * FROM `cursor_user` ORDER BY `name` DESC,`id`",
+		},
+		{
+			name: "whitelist",
+			sort: Sort{SortField: "created ASC,name desc", Fields: fields},
+			want: "SELECT * FROM `cursor_user` ORDER BY `users`.`created_at`,`name` DESC",
+		},
+		{
+			name: "nulls",
+			sort: Sort{SortField: "name asc nulls first,created NULLS LAST", Fields: fields},
+			want: "SELECT * FROM `cursor_user` ORDER BY `name` IS NULL DESC,`name`," +
+				"`users`.`created_at` IS NULL,`users`.`created_at` DESC",
+		},
+		{
+			name:    "unknown_field",
+			sort:    Sort{SortField: "id", Fields: fields},
+			wantErr: true,
+		},
+		{
+			name:    "injection",
+			sort:    Sort{SortField: "id;drop table users"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid_direction",
+			sort:    Sort{SortField: "name up"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid_nulls",
+			sort:    Sort{SortField: "name nulls middle", Fields: fields},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := client.Session(&gorm.Session{DryRun: true}).Model(&cursorUser{})
+			var users []*cursorUser
+			stmt := tt.sort.Build(context.Background(), query).Find(&users).Statement
+			if tt.wantErr {
+				var code e.ErrorCode
+				assert.True(t, errors.As(stmt.Error, &code), "err:%v", stmt.Error)
+				assert.Equal(t, e.ErrInvalidParam.Code(), code.Code())
+				return
+			}
+			require.NoError(t, stmt.Error)
+			assert.Equal(t, tt.want, stmt.SQL.String())
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"net"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/crochee/lirity"
+)
+
+// Replica is a read only copy of the primary, empty fields are the same as the primary
+type Replica struct {
+	IP       string
+	Port     string
+	User     string
+	Password string
+	Database string
+}
+
+type ReplicaPolicy int
+
+const (
+	// PolicyRandom picks a replica randomly
+	PolicyRandom ReplicaPolicy = iota
+	// PolicyLeastConn picks the replica with the least connections in use
+	PolicyLeastConn
+)
+
+type primaryKey struct{}
+
+// WithPrimary returns context whose reads go to the primary, e.g. reading what was just written
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+type resolver struct {
+	policy   ReplicaPolicy
+	eject    time.Duration
+	replicas []*replicaPool
+}
+
+func newResolver(o *Option) (*resolver, error) {
+	r := &resolver{policy: o.ReplicaPolicy, eject: o.EjectDuration}
+	if r.eject <= 0 {
+		r.eject = 30 * time.Second
+	}
+	for _, replica := range o.Replicas {
+		ro := *o
+		if replica.IP != "" {
+			ro.IP = replica.IP
+		}
+		if replica.Port != "" {
+			ro.Port = replica.Port
+		}
+		if replica.User != "" {
+			ro.User, ro.Password = replica.User, replica.Password
+		}
+		if replica.Database != "" {
+			ro.Database = replica.Database
+		}
+		client, err := open(&ro)
+		if err != nil {
+			r.close()
+			return nil, err
+		}
+		var sqlDB *sql.DB
+		if sqlDB, err = client.DB(); err != nil {
+			r.close()
+			return nil, err
+		}
+		r.replicas = append(r.replicas, &replicaPool{ConnPool: client.ConnPool, db: sqlDB, eject: r.eject})
+	}
+	return r, nil
+}
+
+func (r *resolver) register(client *gorm.DB) error {
+	if err := client.Callback().Query().Before("gorm:query").Register("lirity:resolver", r.route); err != nil {
+		return err
+	}
+	return client.Callback().Row().Before("gorm:row").Register("lirity:resolver", r.route)
+}
+
+var readSQL = regexp.MustCompile(`(?is)^\s*select\b`)
+
+var lockSQL = regexp.MustCompile(`(?i)\bfor\s+(update|share)\b|\block\s+in\s+share\s+mode\b`)
+
+// route sends reads out of transactions to a replica
+func (r *resolver) route(db *gorm.DB) {
+	stmt := db.Statement
+	if _, ok := stmt.ConnPool.(gorm.TxCommitter); ok || usePrimary(stmt.Context) {
+		return
+	}
+	if _, ok := stmt.Clauses["FOR"]; ok {
+		return
+	}
+	// Raw构造的sql需要判断是否只读
+	if sqlText := stmt.SQL.String(); sqlText != "" && (!readSQL.MatchString(sqlText) || lockSQL.MatchString(sqlText)) {
+		return
+	}
+	if replica := r.pick(); replica != nil {
+		stmt.ConnPool = replica
+	}
+}
+
+// pick returns nil when all replicas are ejected
+func (r *resolver) pick() *replicaPool {
+	now := time.Now().UnixNano()
+	available := make([]*replicaPool, 0, len(r.replicas))
+	for _, replica := range r.replicas {
+		if atomic.LoadInt64(&replica.ejectedUntil) <= now {
+			available = append(available, replica)
+		}
+	}
+	if len(available) == 0 {
+		return nil
+	}
+	if r.policy != PolicyLeastConn {
+		return available[rand.Intn(len(available))] // nolint:gosec
+	}
+	picked, least := available[0], available[0].db.Stats().InUse
+	for _, replica := range available[1:] {
+		if inUse := replica.db.Stats().InUse; inUse < least {
+			picked, least = replica, inUse
+		}
+	}
+	return picked
+}
+
+func (r *resolver) close() {
+	for _, replica := range r.replicas {
+		lirity.Close(replica.db)
+	}
+}
+
+// replicaPool ejects the replica when the connection fails
+type replicaPool struct {
+	gorm.ConnPool
+	db           *sql.DB
+	eject        time.Duration
+	ejectedUntil int64
+}
+
+func (r *replicaPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := r.ConnPool.QueryContext(ctx, query, args...)
+	r.check(err)
+	return rows, err
+}
+
+func (r *replicaPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row := r.ConnPool.QueryRowContext(ctx, query, args...)
+	r.check(row.Err())
+	return row
+}
+
+func (r *replicaPool) check(err error) {
+	if isConnError(err) {
+		atomic.StoreInt64(&r.ejectedUntil, time.Now().Add(r.eject).UnixNano())
+	}
+}
+
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
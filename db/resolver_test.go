@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver(t *testing.T) {
+	dir := t.TempDir()
+	primary := filepath.Join(dir, "primary.db")
+	replica := filepath.Join(dir, "replica.db")
+	for _, database := range []string{primary, replica} {
+		client, err := New(context.Background(), func(o *Option) {
+			o.Driver = DriverSQLite
+			o.Database = database
+		})
+		require.NoError(t, err)
+		require.NoError(t, client.AutoMigrate(&driverUser{}))
+		require.NoError(t, client.Create(&driverUser{ID: 1, Name: filepath.Base(database)}).Error)
+		require.NoError(t, client.Close())
+	}
+
+	client, err := New(context.Background(), func(o *Option) {
+		o.Driver = DriverSQLite
+		o.Database = primary
+		o.Replicas = []Replica{{Database: replica}}
+		o.ReplicaPolicy = PolicyLeastConn
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	ctx := context.Background()
+	name := func(db *DB) string {
+		var user driverUser
+		require.NoError(t, db.Take(&user, 1).Error)
+		return user.Name
+	}
+	require.Equal(t, "replica.db", name(client.With(ctx)))
+	require.Equal(t, "primary.db", name(client.With(WithPrimary(ctx))))
+
+	var raw string
+	require.NoError(t, client.With(ctx).Raw("SELECT name FROM driver_user WHERE id = ?", 1).Scan(&raw).Error)
+	require.Equal(t, "replica.db", raw)
+
+	require.NoError(t, client.With(ctx).Create(&driverUser{ID: 2, Name: "new"}).Error)
+	var count int64
+	require.NoError(t, client.With(ctx).Model(&driverUser{}).Count(&count).Error)
+	require.Equal(t, int64(1), count)
+	require.NoError(t, client.With(WithPrimary(ctx)).Model(&driverUser{}).Count(&count).Error)
+	require.Equal(t, int64(2), count)
+
+	require.NoError(t, client.Transaction(ctx, func(ctx context.Context) error {
+		var user driverUser
+		require.NoError(t, client.With(ctx).Take(&user, 1).Error)
+		require.Equal(t, "primary.db", user.Name)
+		return nil
+	}))
+
+	// 连接错误后摘除副本
+	client.resolver.replicas[0].check(fmt.Errorf("query,%w", &net.OpError{Op: "dial", Err: driver.ErrBadConn}))
+	require.Equal(t, "primary.db", name(client.With(ctx)))
+}
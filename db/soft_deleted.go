@@ -1,40 +1,71 @@
 package db
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"gorm.io/gorm/schema"
 )
 
+// Deleted is the soft delete flag, 0 means not deleted and a deleted row is set to its primary key
+// or the delete time in nanoseconds when the primary key isn't a single integer,
+// so that a unique index with Deleted ignores the deleted rows, see CreateUniqueIndex.
+// A model may have both Deleted and DeletedAt, they are set together.
 type Deleted uint64
 
-func (d Deleted) UpdateClauses(field *schema.Field) []clause.Interface {
-	return []clause.Interface{SoftDeletedQueryClause{Field: field}}
+func (Deleted) QueryClauses(f *schema.Field) []clause.Interface {
+	return []clause.Interface{SoftDeleteQueryClause{Field: f}}
 }
 
-type SoftDeletedUpdateClause struct {
+func (Deleted) UpdateClauses(f *schema.Field) []clause.Interface {
+	return []clause.Interface{SoftDeleteUpdateClause{Field: f}}
+}
+
+func (Deleted) DeleteClauses(f *schema.Field) []clause.Interface {
+	return []clause.Interface{SoftDeleteDeleteClause{Field: f}}
+}
+
+var (
+	deletedType   = reflect.TypeOf(Deleted(0))
+	deletedAtType = reflect.TypeOf(DeletedAt{})
+)
+
+// Deprecated: use SoftDeleteQueryClause
+type SoftDeletedQueryClause = SoftDeleteQueryClause
+
+// Deprecated: use SoftDeleteUpdateClause
+type SoftDeletedUpdateClause = SoftDeleteUpdateClause
+
+// Deprecated: use SoftDeleteDeleteClause
+type SoftDeleteDeletedClause = SoftDeleteDeleteClause
+
+// SoftDeleteQueryClause queries the rows not deleted of Deleted or DeletedAt Field
+type SoftDeleteQueryClause struct {
 	Field *schema.Field
 }
 
-func (s SoftDeletedUpdateClause) Name() string {
+func (sd SoftDeleteQueryClause) Name() string {
 	return ""
 }
 
-func (s SoftDeletedUpdateClause) Build(clause.Builder) {
+func (sd SoftDeleteQueryClause) Build(clause.Builder) {
 }
 
-func (s SoftDeletedUpdateClause) MergeClause(*clause.Clause) {
+func (sd SoftDeleteQueryClause) MergeClause(*clause.Clause) {
 }
 
-func (s SoftDeletedUpdateClause) ModifyStatement(stmt *gorm.Statement) {
+func (sd SoftDeleteQueryClause) ModifyStatement(stmt *gorm.Statement) {
 	if _, ok := stmt.Clauses["soft_delete_enabled"]; ok {
 		return
 	}
 	if c, ok := stmt.Clauses["WHERE"]; ok {
 		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 1 {
 			for _, expr := range where.Exprs {
+				// 单个OR条件会与软删除条件以OR拼接，需要整体括起来
 				if orCond, ok := expr.(clause.OrConditions); ok && len(orCond.Exprs) == 1 {
 					where.Exprs = []clause.Expression{clause.And(where.Exprs...)}
 					c.Expression = where
@@ -44,114 +75,252 @@ func (s SoftDeletedUpdateClause) ModifyStatement(stmt *gorm.Statement) {
 			}
 		}
 	}
-	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
-		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: s.Field.DBName}, Value: 0},
-	}})
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{notDeleted(sd.Field)}})
 	stmt.Clauses["soft_delete_enabled"] = clause.Clause{}
 }
 
-func (d Deleted) QueryClauses(f *schema.Field) []clause.Interface {
-	return []clause.Interface{SoftDeletedQueryClause{Field: f}}
-}
-
-type SoftDeletedQueryClause struct {
+// SoftDeleteUpdateClause updates the rows not deleted only, use Unscoped or Restore to update deleted rows
+type SoftDeleteUpdateClause struct {
 	Field *schema.Field
 }
 
-func (s SoftDeletedQueryClause) Name() string {
+func (sd SoftDeleteUpdateClause) Name() string {
 	return ""
 }
 
-func (s SoftDeletedQueryClause) Build(clause.Builder) {
+func (sd SoftDeleteUpdateClause) Build(clause.Builder) {
 }
 
-func (s SoftDeletedQueryClause) MergeClause(*clause.Clause) {
+func (sd SoftDeleteUpdateClause) MergeClause(*clause.Clause) {
 }
 
-func (s SoftDeletedQueryClause) ModifyStatement(stmt *gorm.Statement) {
-	if _, ok := stmt.Clauses["soft_delete_enabled"]; ok {
-		return
+func (sd SoftDeleteUpdateClause) ModifyStatement(stmt *gorm.Statement) {
+	if stmt.SQL.String() == "" {
+		SoftDeleteQueryClause(sd).ModifyStatement(stmt)
 	}
-	if c, ok := stmt.Clauses["WHERE"]; ok {
-		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 1 {
-			for _, expr := range where.Exprs {
-				if orCond, ok := expr.(clause.OrConditions); ok && len(orCond.Exprs) == 1 {
-					where.Exprs = []clause.Expression{clause.And(where.Exprs...)}
-					c.Expression = where
-					stmt.Clauses["WHERE"] = c
-					break
-				}
-			}
-		}
-	}
-	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
-		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: s.Field.DBName}, Value: 0},
-	}})
-	stmt.Clauses["soft_delete_enabled"] = clause.Clause{}
 }
 
-func (d Deleted) DeleteClauses(f *schema.Field) []clause.Interface {
-	return []clause.Interface{SoftDeleteDeletedClause{Field: f}}
-}
-
-type SoftDeleteDeletedClause struct {
+// SoftDeleteDeleteClause updates all Deleted and DeletedAt fields of the model instead of deleting
+type SoftDeleteDeleteClause struct {
 	Field *schema.Field
 }
 
-func (s SoftDeleteDeletedClause) Name() string {
+func (sd SoftDeleteDeleteClause) Name() string {
 	return ""
 }
 
-func (s SoftDeleteDeletedClause) Build(clause.Builder) {
-
+func (sd SoftDeleteDeleteClause) Build(clause.Builder) {
 }
 
-func (s SoftDeleteDeletedClause) MergeClause(*clause.Clause) {
+func (sd SoftDeleteDeleteClause) MergeClause(*clause.Clause) {
 }
 
-func (s SoftDeleteDeletedClause) ModifyStatement(stmt *gorm.Statement) {
+func (sd SoftDeleteDeleteClause) ModifyStatement(stmt *gorm.Statement) {
+	// 同时有Deleted和DeletedAt时只处理一次
 	if stmt.SQL.String() != "" {
 		return
 	}
-	assignment := clause.Assignment{
-		Column: clause.Column{Name: s.Field.DBName},
-		Value:  gorm.Expr("`id`"),
-	}
 	curTime := stmt.DB.NowFunc()
-	_, idOk := stmt.Schema.FieldsByDBName["id"]
-	if !idOk {
-		assignment.Value = uint64(curTime.UnixNano())
-		return
-	}
-	clauseSet := clause.Set{assignment}
-	if _, ok := stmt.Schema.FieldsByName["DeletedAt"]; ok {
-		clauseSet = append(clauseSet, clause.Assignment{Column: clause.Column{Name: "deleted_at"}, Value: curTime})
+	var clauseSet clause.Set
+	for _, field := range softDeleteFields(stmt.Schema) {
+		assignment := clause.Assignment{Column: clause.Column{Name: field.DBName}, Value: curTime}
+		if field.IndirectFieldType == deletedType {
+			assignment.Value = deletedValue(stmt.Schema, curTime)
+		}
+		clauseSet = append(clauseSet, assignment)
 	}
 	stmt.AddClause(clauseSet)
 
 	if stmt.Schema != nil {
-		_, queryValues := schema.GetIdentityFieldValuesMap(stmt.ReflectValue, stmt.Schema.PrimaryFields)
-		column, values := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, queryValues)
-
-		if len(values) > 0 {
-			stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}})
+		if expr := primaryKeys(stmt.Schema, stmt.Table, stmt.ReflectValue); expr != nil {
+			stmt.AddClause(clause.Where{Exprs: []clause.Expression{expr}})
 		}
-
 		if stmt.ReflectValue.CanAddr() && stmt.Dest != stmt.Model && stmt.Model != nil {
-			_, queryValues = schema.GetIdentityFieldValuesMap(reflect.ValueOf(stmt.Model), stmt.Schema.PrimaryFields)
-			column, values = schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, queryValues)
-
-			if len(values) > 0 {
-				stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}})
+			if expr := primaryKeys(stmt.Schema, stmt.Table, reflect.ValueOf(stmt.Model)); expr != nil {
+				stmt.AddClause(clause.Where{Exprs: []clause.Expression{expr}})
 			}
 		}
 	}
+
 	if _, ok := stmt.Clauses["WHERE"]; !stmt.DB.AllowGlobalUpdate && !ok {
 		_ = stmt.DB.AddError(gorm.ErrMissingWhereClause)
 	} else {
-		SoftDeletedQueryClause{Field: s.Field}.ModifyStatement(stmt)
+		SoftDeleteQueryClause{Field: sd.Field}.ModifyStatement(stmt)
 	}
 
 	stmt.AddClauseIfNotExists(clause.Update{})
 	stmt.Build("UPDATE", "SET", "WHERE")
 }
+
+// primaryKeys is the condition of the primary keys of value,
+// composite primary keys are compared one by one as sqlite doesn't support IN of row values
+func primaryKeys(s *schema.Schema, table string, value reflect.Value) clause.Expression {
+	_, queryValues := schema.GetIdentityFieldValuesMap(value, s.PrimaryFields)
+	if len(queryValues) == 0 {
+		return nil
+	}
+	if len(s.PrimaryFields) == 1 {
+		column, values := schema.ToQueryValues(table, s.PrimaryFieldDBNames, queryValues)
+		return clause.IN{Column: column, Values: values}
+	}
+	ors := make([]clause.Expression, 0, len(queryValues))
+	for _, values := range queryValues {
+		ands := make([]clause.Expression, 0, len(values))
+		for i, field := range s.PrimaryFields {
+			ands = append(ands, clause.Eq{Column: clause.Column{Table: table, Name: field.DBName}, Value: values[i]})
+		}
+		ors = append(ors, clause.And(ands...))
+	}
+	if len(ors) == 1 {
+		return ors[0]
+	}
+	return clause.Or(ors...)
+}
+
+func softDeleteFields(s *schema.Schema) []*schema.Field {
+	var fields []*schema.Field
+	for _, field := range s.Fields {
+		if field.DBName != "" && (field.IndirectFieldType == deletedType || field.IndirectFieldType == deletedAtType) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// deletedValue is the single integer primary key, or the delete time when there are composite primary keys
+func deletedValue(s *schema.Schema, now time.Time) interface{} {
+	if len(s.PrimaryFields) == 1 {
+		if field := s.PrimaryFields[0]; field.DataType == schema.Int || field.DataType == schema.Uint {
+			return clause.Column{Name: field.DBName}
+		}
+	}
+	return uint64(now.UnixNano())
+}
+
+func notDeleted(field *schema.Field) clause.Expression {
+	column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+	if field.IndirectFieldType == deletedAtType {
+		return clause.Eq{Column: column, Value: nil}
+	}
+	return clause.Eq{Column: column, Value: 0}
+}
+
+func isDeleted(field *schema.Field) clause.Expression {
+	column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+	if field.IndirectFieldType == deletedAtType {
+		return clause.Neq{Column: column, Value: nil}
+	}
+	return clause.Neq{Column: column, Value: 0}
+}
+
+func parseSoftDelete(tx *gorm.DB, value interface{}) (*schema.Schema, []*schema.Field, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(value); err != nil {
+		return nil, nil, err
+	}
+	fields := softDeleteFields(stmt.Schema)
+	if len(fields) == 0 {
+		return nil, nil, fmt.Errorf("%s has no Deleted or DeletedAt field", stmt.Schema.Name)
+	}
+	return stmt.Schema, fields, nil
+}
+
+// OnlyDeleted is a scope querying the soft deleted rows only, e.g. With(ctx).Scopes(OnlyDeleted).Find(&users),
+// use Unscoped to query both deleted and not deleted rows
+func OnlyDeleted(tx *gorm.DB) *gorm.DB {
+	value := tx.Statement.Model
+	if value == nil {
+		value = tx.Statement.Dest
+	}
+	_, fields, err := parseSoftDelete(tx, value)
+	if err != nil {
+		_ = tx.AddError(err)
+		return tx
+	}
+	return tx.Unscoped().Where(isDeleted(fields[0]))
+}
+
+// Restore undoes the soft delete of the rows matched by the primary keys of value and conds,
+// RowsAffected of the result is the number of restored rows
+func (d *DB) Restore(value interface{}, conds ...interface{}) *gorm.DB {
+	s, fields, err := parseSoftDelete(d.DB, value)
+	if err != nil {
+		tx := d.DB.Session(&gorm.Session{})
+		_ = tx.AddError(err)
+		return tx
+	}
+	if len(conds) == 0 && !d.AllowGlobalUpdate {
+		// 软删除条件会使WHERE非空，需自行检查是否会恢复全表
+		_, values := schema.GetIdentityFieldValuesMap(reflect.Indirect(reflect.ValueOf(value)), s.PrimaryFields)
+		if len(values) == 0 {
+			tx := d.DB.Session(&gorm.Session{})
+			_ = tx.AddError(gorm.ErrMissingWhereClause)
+			return tx
+		}
+	}
+	updates := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if field.IndirectFieldType == deletedAtType {
+			updates[field.DBName] = nil
+		} else {
+			updates[field.DBName] = 0
+		}
+	}
+	tx := d.Unscoped().Model(value)
+	if len(conds) > 0 {
+		tx = tx.Where(conds[0], conds[1:]...)
+	}
+	return tx.Where(isDeleted(fields[0])).Updates(updates)
+}
+
+// Purge hard deletes the rows of value soft deleted before, value needs a DeletedAt field
+func (d *DB) Purge(value interface{}, before time.Time) *gorm.DB {
+	_, fields, err := parseSoftDelete(d.DB, value)
+	for _, field := range fields {
+		if field.IndirectFieldType == deletedAtType {
+			return d.Unscoped().Where(clause.Lt{
+				Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+				Value:  before,
+			}).Delete(value)
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("%T has no DeletedAt field", value)
+	}
+	tx := d.DB.Session(&gorm.Session{})
+	_ = tx.AddError(err)
+	return tx
+}
+
+// CreateUniqueIndex creates unique index name of columns among the rows not soft deleted,
+// it is a partial index on postgres and sqlite, mysql doesn't support partial index
+// so the Deleted field of value is appended to the columns and is required
+func (d *DB) CreateUniqueIndex(value interface{}, name string, columns ...string) error {
+	s, fields, err := parseSoftDelete(d.DB, value)
+	if err != nil {
+		return err
+	}
+	quoted := make([]string, 0, len(columns)+1)
+	for _, column := range columns {
+		quoted = append(quoted, d.Statement.Quote(column))
+	}
+	var where string
+	if d.Dialector.Name() != DriverMySQL {
+		// DDL不支持绑定变量
+		if where = " WHERE " + d.Statement.Quote(fields[0].DBName) + " = 0"; fields[0].IndirectFieldType == deletedAtType {
+			where = " WHERE " + d.Statement.Quote(fields[0].DBName) + " IS NULL"
+		}
+	} else {
+		for _, field := range fields {
+			if field.IndirectFieldType == deletedType {
+				quoted = append(quoted, d.Statement.Quote(field.DBName))
+				break
+			}
+		}
+		if len(quoted) == len(columns) {
+			return fmt.Errorf("%s has no Deleted field for unique index on mysql", s.Name)
+		}
+	}
+	return d.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s)%s",
+		d.Statement.Quote(name), d.Statement.Quote(s.Table), strings.Join(quoted, ","), where)).Error
+}
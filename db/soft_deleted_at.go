@@ -3,16 +3,15 @@ package db
 import (
 	"database/sql"
 	"database/sql/driver"
-	"reflect"
 
 	"github.com/json-iterator/go"
-	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"gorm.io/gorm/schema"
 
 	"github.com/crochee/lirity"
 )
 
+// DeletedAt is the soft delete time, null means not deleted, see Deleted
 type DeletedAt sql.NullTime
 
 // Scan implements the Scanner interface.
@@ -51,107 +50,10 @@ func (DeletedAt) QueryClauses(f *schema.Field) []clause.Interface {
 	return []clause.Interface{SoftDeleteQueryClause{Field: f}}
 }
 
-type SoftDeleteQueryClause struct {
-	Field *schema.Field
-}
-
-func (sd SoftDeleteQueryClause) Name() string {
-	return ""
-}
-
-func (sd SoftDeleteQueryClause) Build(clause.Builder) {
-}
-
-func (sd SoftDeleteQueryClause) MergeClause(*clause.Clause) {
-}
-
-func (sd SoftDeleteQueryClause) ModifyStatement(stmt *gorm.Statement) {
-	if _, ok := stmt.Clauses["soft_delete_enabled"]; ok {
-		return
-	}
-	if c, ok := stmt.Clauses["WHERE"]; ok {
-		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 1 {
-			for _, expr := range where.Exprs {
-				if orCond, ok := expr.(clause.OrConditions); ok && len(orCond.Exprs) == 1 {
-					where.Exprs = []clause.Expression{clause.And(where.Exprs...)}
-					c.Expression = where
-					stmt.Clauses["WHERE"] = c
-					break
-				}
-			}
-		}
-	}
-
-	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
-		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: sd.Field.DBName}, Value: nil},
-	}})
-	stmt.Clauses["soft_delete_enabled"] = clause.Clause{}
+func (DeletedAt) UpdateClauses(f *schema.Field) []clause.Interface {
+	return []clause.Interface{SoftDeleteUpdateClause{Field: f}}
 }
 
 func (DeletedAt) DeleteClauses(f *schema.Field) []clause.Interface {
 	return []clause.Interface{SoftDeleteDeleteClause{Field: f}}
 }
-
-type SoftDeleteDeleteClause struct {
-	Field *schema.Field
-}
-
-func (sd SoftDeleteDeleteClause) Name() string {
-	return ""
-}
-
-func (sd SoftDeleteDeleteClause) Build(clause.Builder) {
-}
-
-func (sd SoftDeleteDeleteClause) MergeClause(*clause.Clause) {
-}
-
-func (sd SoftDeleteDeleteClause) ModifyStatement(stmt *gorm.Statement) {
-	if stmt.SQL.String() != "" {
-		return
-	}
-	var clauseSet clause.Set
-	curTime := stmt.DB.NowFunc()
-	clauseSet = append(clauseSet, clause.Assignment{Column: clause.Column{Name: sd.Field.DBName}, Value: curTime})
-
-	if _, ok := stmt.Schema.FieldsByName["Deleted"]; ok {
-		assignment := clause.Assignment{
-			Column: clause.Column{Name: "deleted"},
-			Value:  gorm.Expr("`id`"),
-		}
-		_, idOk := stmt.Schema.FieldsByDBName["id"]
-		if !idOk {
-			assignment.Value = uint64(curTime.UnixNano())
-			return
-		}
-		clauseSet = append(clauseSet, assignment)
-	}
-
-	stmt.AddClause(clauseSet)
-	if stmt.Schema != nil {
-		_, queryValues := schema.GetIdentityFieldValuesMap(stmt.ReflectValue, stmt.Schema.PrimaryFields)
-		column, values := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, queryValues)
-
-		if len(values) > 0 {
-			stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}})
-		}
-
-		if stmt.ReflectValue.CanAddr() && stmt.Dest != stmt.Model && stmt.Model != nil {
-			_, queryValues = schema.GetIdentityFieldValuesMap(reflect.ValueOf(stmt.Model), stmt.Schema.PrimaryFields)
-			column, values = schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, queryValues)
-
-			if len(values) > 0 {
-				stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}})
-			}
-		}
-	}
-
-	if _, ok := stmt.Clauses["WHERE"]; !stmt.DB.AllowGlobalUpdate && !ok {
-		_ = stmt.DB.AddError(gorm.ErrMissingWhereClause)
-	} else {
-		SoftDeleteQueryClause{Field: sd.Field}.ModifyStatement(stmt)
-	}
-
-	stmt.AddClauseIfNotExists(clause.Update{})
-	stmt.Build("UPDATE", "SET", "WHERE")
-}
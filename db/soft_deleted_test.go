@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type softUser struct {
+	ID        uint `gorm:"primaryKey"`
+	Name      string
+	Deleted   Deleted
+	DeletedAt DeletedAt
+}
+
+type softMember struct {
+	Tenant  string `gorm:"primaryKey;size:32"`
+	Name    string `gorm:"primaryKey;size:32"`
+	Role    string
+	Deleted Deleted
+}
+
+type softLog struct {
+	ID        uint `gorm:"primaryKey"`
+	DeletedAt DeletedAt
+}
+
+func newSoftDB(t *testing.T) *DB {
+	client, err := New(context.Background(), func(o *Option) { o.Driver = DriverSQLite })
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	require.NoError(t, client.AutoMigrate(&softUser{}, &softMember{}, &softLog{}))
+	return client
+}
+
+func TestSoftDelete(t *testing.T) {
+	client := newSoftDB(t)
+	require.NoError(t, client.CreateUniqueIndex(&softUser{}, "idx_soft_user_name", "name"))
+	users := []*softUser{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+	require.NoError(t, client.Create(&users).Error)
+
+	require.NoError(t, client.Delete(users[0]).Error)
+	var deleted softUser
+	require.NoError(t, client.Unscoped().First(&deleted, 1).Error)
+	assert.Equal(t, Deleted(1), deleted.Deleted)
+	assert.True(t, deleted.DeletedAt.Valid)
+	require.True(t, errors.Is(client.First(&softUser{}, 1).Error, NotFound))
+
+	// 已删除的行不被更新
+	result := client.Model(&softUser{}).Where("id IN ?", []uint{1, 2}).Update("name", "c")
+	require.NoError(t, result.Error)
+	assert.Equal(t, int64(1), result.RowsAffected)
+
+	// 唯一索引忽略已删除的行
+	require.NoError(t, client.Create(&softUser{ID: 3, Name: "a"}).Error)
+	require.Error(t, client.Create(&softUser{ID: 4, Name: "a"}).Error)
+
+	var list []*softUser
+	require.NoError(t, client.Scopes(OnlyDeleted).Find(&list).Error)
+	require.Len(t, list, 1)
+	assert.Equal(t, uint(1), list[0].ID)
+
+	require.True(t, errors.Is(client.Restore(&softUser{}).Error, gorm.ErrMissingWhereClause))
+	// 恢复会与未删除的行冲突
+	require.True(t, errors.Is(client.Restore(&softUser{ID: 1}).Error, ErrDuplicateKey))
+	require.NoError(t, client.Delete(&softUser{ID: 3}).Error)
+	result = client.Restore(&softUser{ID: 1})
+	require.NoError(t, result.Error)
+	assert.Equal(t, int64(1), result.RowsAffected)
+	var restored softUser
+	require.NoError(t, client.First(&restored, 1).Error)
+	assert.Equal(t, Deleted(0), restored.Deleted)
+	assert.False(t, restored.DeletedAt.Valid)
+
+	require.True(t, errors.Is(client.Restore(&softUser{}, "name = ?", "a").Error, ErrDuplicateKey))
+}
+
+func TestSoftDeleteCompositeKey(t *testing.T) {
+	client := newSoftDB(t)
+	members := []*softMember{{Tenant: "t1", Name: "a"}, {Tenant: "t1", Name: "b"}, {Tenant: "t2", Name: "a"}}
+	require.NoError(t, client.Create(&members).Error)
+	require.NoError(t, client.Delete(&softMember{Tenant: "t1", Name: "a"}).Error)
+	require.True(t, errors.Is(client.Delete(&softMember{}).Error, gorm.ErrMissingWhereClause))
+
+	var list []*softMember
+	require.NoError(t, client.Order("tenant,name").Find(&list).Error)
+	require.Len(t, list, 2)
+	assert.Equal(t, "b", list[0].Name)
+	assert.Equal(t, "t2", list[1].Tenant)
+
+	var deleted softMember
+	require.NoError(t, client.Unscoped().Where("tenant = ? AND name = ?", "t1", "a").First(&deleted).Error)
+	assert.NotZero(t, deleted.Deleted)
+
+	require.NoError(t, client.Delete(&[]*softMember{members[1], members[2]}).Error)
+	require.NoError(t, client.Find(&list).Error)
+	assert.Len(t, list, 0)
+	require.NoError(t, client.Restore(&[]*softMember{members[0], members[1], members[2]}).Error)
+	require.NoError(t, client.Find(&list).Error)
+	assert.Len(t, list, 3)
+}
+
+func TestPurge(t *testing.T) {
+	client := newSoftDB(t)
+	require.NoError(t, client.Create(&[]*softLog{{ID: 1}, {ID: 2}, {ID: 3}}).Error)
+	require.NoError(t, client.Delete(&softLog{}, []uint{1, 2}).Error)
+	require.NoError(t, client.Unscoped().Model(&softLog{ID: 2}).
+		Update("deleted_at", time.Now().Add(-48*time.Hour)).Error)
+
+	result := client.Purge(&softLog{}, time.Now().Add(-24*time.Hour))
+	require.NoError(t, result.Error)
+	assert.Equal(t, int64(1), result.RowsAffected)
+	var count int64
+	require.NoError(t, client.Unscoped().Model(&softLog{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+
+	require.Error(t, client.Purge(&softMember{}, time.Now()).Error)
+}
+
+func TestCreateUniqueIndex(t *testing.T) {
+	mock, err := Mock()
+	require.NoError(t, err)
+	mock.ExpectExec(regexp.QuoteMeta("CREATE UNIQUE INDEX `idx_name` ON `soft_user` (`name`,`deleted`)")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, With(context.Background()).CreateUniqueIndex(&softUser{}, "idx_name", "name"))
+	require.Error(t, With(context.Background()).CreateUniqueIndex(&softLog{}, "idx_id", "id"))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	mock, err = Mock(func(o *Option) { o.Driver = DriverPostgres })
+	require.NoError(t, err)
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE UNIQUE INDEX "idx_id" ON "soft_log" ("id") WHERE "deleted_at" IS NULL`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, With(context.Background()).CreateUniqueIndex(&softLog{}, "idx_id", "id"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
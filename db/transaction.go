@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+type TxOption struct {
+	// Attempts runs fn at most, only the outermost transaction is retried
+	Attempts int
+	// Backoff before the first retry and doubled for every retry
+	Backoff time.Duration
+	// Retryable decides whether the transaction should be retried, default deadlock and lock wait timeout
+	Retryable func(err error) bool
+	TxOptions *sql.TxOptions
+}
+
+// Transaction runs fn in a transaction which is committed when fn returns nil and rolled back on error or panic.
+// The transaction is stored in the ctx passed to fn, With(ctx) and nested Transaction use it
+// and nested ones are savepoints. Nested ones ignore opts, they are neither retried nor have TxOptions,
+// the outermost Transaction retries the whole transaction.
+// It shadows Transaction of the embedded *gorm.DB, which is still available by d.DB.Transaction.
+func (d *DB) Transaction(ctx context.Context, fn func(ctx context.Context) error, opts ...func(*TxOption)) error {
+	if tx, ok := ctx.Value(txKey{}).(*DB); ok {
+		return tx.savepoint(ctx, fn)
+	}
+	o := TxOption{
+		Attempts:  3,
+		Backoff:   50 * time.Millisecond,
+		Retryable: IsDeadlock,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Retryable == nil {
+		o.Retryable = IsDeadlock
+	}
+	backoff := o.Backoff
+	for attempt := 1; ; attempt++ {
+		err := d.transaction(ctx, fn, o.TxOptions)
+		if err == nil || attempt >= o.Attempts || !o.Retryable(err) {
+			return err
+		}
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)) // nolint:gosec
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (d *DB) transaction(ctx context.Context, fn func(ctx context.Context) error, opts *sql.TxOptions) error {
+	var txOpts []*sql.TxOptions
+	if opts != nil {
+		txOpts = append(txOpts, opts)
+	}
+	// WithContext保留会话的logger
+	err := d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, &DB{DB: tx, debug: d.debug, resolver: d.resolver}))
+	}, txOpts...)
+	// 提交时的错误未经过回调
+	return Classify(err)
+}
+
+var savepointID uint64
+
+// savepoint runs fn in a savepoint of the transaction which is released when fn succeeds,
+// SAVEPOINT, RELEASE SAVEPOINT and ROLLBACK TO SAVEPOINT are supported by mysql, postgres and sqlite
+func (d *DB) savepoint(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	name := fmt.Sprintf("sp%d", atomic.AddUint64(&savepointID, 1))
+	tx := d.DB.WithContext(ctx)
+	if err = tx.Exec("SAVEPOINT " + name).Error; err != nil {
+		return err
+	}
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			tx.Exec("ROLLBACK TO SAVEPOINT " + name)
+		}
+	}()
+	err = fn(ctx)
+	panicked = false
+	if err != nil {
+		return err
+	}
+	return tx.Exec("RELEASE SAVEPOINT " + name).Error
+}
+
+// IsDeadlock reports whether err is a deadlock or lock wait timeout of any driver
+func IsDeadlock(err error) bool {
+	err = Classify(err)
+	return errors.Is(err, ErrDeadlock) || errors.Is(err, ErrLockTimeout)
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransaction(t *testing.T) {
+	client, err := New(context.Background(), func(o *Option) {
+		o.Driver = DriverSQLite
+		o.Database = filepath.Join(t.TempDir(), "tx.db")
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	require.NoError(t, client.AutoMigrate(&driverUser{}))
+	ctx := context.Background()
+	count := func() int64 {
+		var n int64
+		require.NoError(t, client.With(ctx).Model(&driverUser{}).Count(&n).Error)
+		return n
+	}
+
+	errInner := errors.New("inner")
+	require.NoError(t, client.Transaction(ctx, func(ctx context.Context) error {
+		require.NoError(t, client.With(ctx).Create(&driverUser{Name: "a"}).Error)
+		// 内层回滚到保存点，不影响外层
+		require.ErrorIs(t, client.Transaction(ctx, func(ctx context.Context) error {
+			require.NoError(t, client.With(ctx).Create(&driverUser{Name: "b"}).Error)
+			return errInner
+		}), errInner)
+		return client.Transaction(ctx, func(ctx context.Context) error {
+			return client.With(ctx).Create(&driverUser{Name: "c"}).Error
+		})
+	}))
+	require.Equal(t, int64(2), count())
+
+	require.Panics(t, func() {
+		_ = client.Transaction(ctx, func(ctx context.Context) error {
+			require.NoError(t, client.With(ctx).Create(&driverUser{Name: "d"}).Error)
+			panic("oops")
+		})
+	})
+	require.Equal(t, int64(2), count())
+
+	attempts := 0
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	err = client.Transaction(ctx, func(ctx context.Context) error {
+		attempts++
+		require.NoError(t, client.With(ctx).Create(&driverUser{Name: "e"}).Error)
+		if attempts < 3 {
+			return deadlock
+		}
+		return nil
+	}, func(o *TxOption) {
+		o.Backoff = 0
+		// nil使用默认值
+		o.Retryable = nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+	require.Equal(t, int64(3), count())
+
+	attempts = 0
+	err = client.Transaction(ctx, func(ctx context.Context) error {
+		attempts++
+		return errInner
+	})
+	require.ErrorIs(t, err, errInner)
+	require.Equal(t, 1, attempts)
+}
+
+func TestSavepointRelease(t *testing.T) {
+	mock, err := Mock()
+	require.NoError(t, err)
+	mock.ExpectBegin()
+	mock.ExpectExec(`^SAVEPOINT sp\d+$`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`^RELEASE SAVEPOINT sp\d+$`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	ctx := context.Background()
+	require.NoError(t, With(ctx).Transaction(ctx, func(ctx context.Context) error {
+		return With(ctx).Transaction(ctx, func(ctx context.Context) error {
+			return nil
+		})
+	}))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrVersionConflict the row is updated or deleted by others since its Version was read
+var ErrVersionConflict = errors.New("version conflict")
+
+// VersionConflictError is returned by the update whose Version is out of date, errors.Is(err, ErrVersionConflict)
+type VersionConflictError struct {
+	Table   string
+	Version int64
+}
+
+func (v *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s,%s version %d", ErrVersionConflict, v.Table, v.Version)
+}
+
+func (v *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+// Version is the optimistic lock embedded in models like Base, e.g.
+//
+//	type User struct {
+//		Base
+//		Version
+//		Name string
+//	}
+//
+// It is 1 on create. Updating a model whose Version is read adds WHERE version = ? and increases it,
+// *VersionConflictError is returned when no row is updated. Updates of a model without Version
+// are not checked, but the map ones still increase it.
+type Version int64
+
+// Scan implements the Scanner interface.
+func (v *Version) Scan(value interface{}) error {
+	var n sql.NullInt64
+	if err := n.Scan(value); err != nil {
+		return err
+	}
+	*v = Version(n.Int64)
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (v Version) Value() (driver.Value, error) {
+	return int64(v), nil
+}
+
+func (Version) CreateClauses(f *schema.Field) []clause.Interface {
+	return []clause.Interface{VersionCreateClause{Field: f}}
+}
+
+func (Version) UpdateClauses(f *schema.Field) []clause.Interface {
+	return []clause.Interface{VersionUpdateClause{Field: f}}
+}
+
+// VersionCreateClause sets zero Version to 1
+type VersionCreateClause struct {
+	Field *schema.Field
+}
+
+func (v VersionCreateClause) Name() string {
+	return ""
+}
+
+func (v VersionCreateClause) Build(clause.Builder) {
+}
+
+func (v VersionCreateClause) MergeClause(*clause.Clause) {
+}
+
+func (v VersionCreateClause) ModifyStatement(stmt *gorm.Statement) {
+	if stmt.SQL.String() != "" {
+		return
+	}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			v.init(reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		v.init(stmt.ReflectValue)
+	}
+}
+
+func (v VersionCreateClause) init(value reflect.Value) {
+	if _, isZero := v.Field.ValueOf(value); isZero && value.CanAddr() {
+		_ = v.Field.Set(value, int64(1))
+	}
+}
+
+type versionKey struct{}
+
+type versionState struct {
+	field *schema.Field
+	model reflect.Value
+	old   int64
+}
+
+// VersionUpdateClause adds the condition of Version and increases it
+type VersionUpdateClause struct {
+	Field *schema.Field
+}
+
+func (v VersionUpdateClause) Name() string {
+	return ""
+}
+
+func (v VersionUpdateClause) Build(clause.Builder) {
+}
+
+func (v VersionUpdateClause) MergeClause(*clause.Clause) {
+}
+
+func (v VersionUpdateClause) ModifyStatement(stmt *gorm.Statement) {
+	if stmt.SQL.String() != "" || stmt.ReflectValue.Kind() != reflect.Struct {
+		return
+	}
+	value, isZero := v.Field.ValueOf(stmt.ReflectValue)
+	var current int64
+	if !isZero {
+		current = reflect.ValueOf(value).Int()
+	}
+	if dest, ok := stmt.Dest.(map[string]interface{}); ok {
+		// 不修改调用方的map
+		updates := make(map[string]interface{}, len(dest)+1)
+		for k, value := range dest {
+			updates[k] = value
+		}
+		delete(updates, v.Field.Name)
+		if isZero {
+			updates[v.Field.DBName] = gorm.Expr("? + 1", clause.Column{Name: v.Field.DBName})
+		} else {
+			updates[v.Field.DBName] = current + 1
+		}
+		stmt.Dest = updates
+	} else {
+		if isZero {
+			return
+		}
+		destValue := reflect.ValueOf(stmt.Dest)
+		for destValue.Kind() == reflect.Ptr {
+			destValue = destValue.Elem()
+		}
+		if destValue.Kind() != reflect.Struct {
+			return
+		}
+		field := v.Field
+		if destValue.Type() != stmt.ReflectValue.Type() {
+			destStmt := &gorm.Statement{DB: stmt.DB}
+			if err := destStmt.Parse(stmt.Dest); err != nil {
+				return
+			}
+			if field = destStmt.Schema.LookUpField(v.Field.DBName); field == nil {
+				return
+			}
+		}
+		if !destValue.CanAddr() {
+			// Updates(User{...})传入的结构体不可修改
+			copied := reflect.New(destValue.Type())
+			copied.Elem().Set(destValue)
+			destValue = copied.Elem()
+			stmt.Dest = copied.Interface()
+		}
+		if err := field.Set(destValue, current+1); err != nil {
+			_ = stmt.AddError(err)
+			return
+		}
+	}
+	if len(stmt.Selects) > 0 {
+		selected := false
+		for _, column := range stmt.Selects {
+			if column == "*" || column == v.Field.Name || column == v.Field.DBName {
+				selected = true
+				break
+			}
+		}
+		if !selected {
+			stmt.Selects = append(stmt.Selects, v.Field.DBName)
+		}
+	}
+	if isZero {
+		return
+	}
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: v.Field.DBName}, Value: current},
+	}})
+	stmt.Settings.Store(versionKey{}, &versionState{field: v.Field, model: stmt.ReflectValue, old: current})
+}
+
+// registerVersion returns *VersionConflictError when the update with Version affects no row
+func registerVersion(client *gorm.DB) error {
+	return client.Callback().Update().After("gorm:update").Register("lirity:version", func(db *gorm.DB) {
+		value, ok := db.Statement.Settings.LoadAndDelete(versionKey{})
+		if !ok {
+			return
+		}
+		state := value.(*versionState)
+		if db.Error == nil && db.RowsAffected == 0 && !db.DryRun {
+			_ = db.AddError(&VersionConflictError{Table: db.Statement.Table, Version: state.old})
+		}
+		if db.Error != nil && state.model.CanAddr() {
+			// 更新失败时还原内存中的版本
+			_ = state.field.Set(state.model, state.old)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type versionUser struct {
+	ID uint `gorm:"primaryKey"`
+	Version
+	Name string
+	Age  int
+}
+
+func TestVersion(t *testing.T) {
+	client, err := New(context.Background(), func(o *Option) { o.Driver = DriverSQLite })
+	require.NoError(t, err)
+	defer client.Close()
+	require.NoError(t, client.AutoMigrate(&versionUser{}))
+
+	user := &versionUser{ID: 1, Name: "a"}
+	require.NoError(t, client.Create(user).Error)
+	assert.Equal(t, Version(1), user.Version)
+
+	var stale versionUser
+	require.NoError(t, client.First(&stale, 1).Error)
+
+	user.Name = "b"
+	require.NoError(t, client.Save(user).Error)
+	assert.Equal(t, Version(2), user.Version)
+	require.NoError(t, client.Model(user).Updates(versionUser{Age: 18}).Error)
+	assert.Equal(t, Version(3), user.Version)
+	require.NoError(t, client.Model(user).Updates(map[string]interface{}{"name": "c"}).Error)
+	assert.Equal(t, Version(4), user.Version)
+
+	// 过期的版本更新失败
+	stale.Name = "d"
+	err = client.Save(&stale).Error
+	require.True(t, errors.Is(err, ErrVersionConflict))
+	assert.Equal(t, Version(1), stale.Version)
+	assert.Equal(t, "4090000005", ToErrorCode(err).Code())
+	err = client.Model(&stale).Update("name", "d").Error
+	require.True(t, errors.Is(err, ErrVersionConflict))
+	assert.Equal(t, Version(1), stale.Version)
+
+	var count int64
+	require.NoError(t, client.Model(&versionUser{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	var got versionUser
+	require.NoError(t, client.First(&got, 1).Error)
+	assert.Equal(t, versionUser{ID: 1, Version: 4, Name: "c", Age: 18}, got)
+
+	// 未读取版本的更新不检查但会递增
+	require.NoError(t, client.Model(&versionUser{}).Where("id = ?", 1).Update("age", 20).Error)
+	require.NoError(t, client.First(&got, 1).Error)
+	assert.Equal(t, Version(5), got.Version)
+}
@@ -126,6 +126,8 @@ var (
 	ErrNotFound            = Froze("4040000002", "资源不存在")
 	ErrNotAllowMethod      = Froze("4050000003", "不允许此方法")
 	ErrParseContent        = Froze("5000000004", "解析内容失败")
+	ErrConflict            = Froze("4090000005", "资源冲突")
+	ErrServiceUnavailable  = Froze("5030000006", "服务暂不可用")
 )
 
 // AddCode business code to codeMessageBox
@@ -137,6 +139,8 @@ func AddCode(m map[ErrorCode]struct{}) error {
 		ErrNotFound:            {},
 		ErrNotAllowMethod:      {},
 		ErrParseContent:        {},
+		ErrConflict:            {},
+		ErrServiceUnavailable:  {},
 	} {
 		if err := validateErrorCode(errorCode); err != nil {
 			return err
@@ -0,0 +1,52 @@
+package mq
+
+import "time"
+
+// UnknownName is the executor name recorded when a delivery can't be decoded
+const UnknownName = "unknown"
+
+// Metrics is the instrumentation hook of Client, producers and consumers.
+// name is the executor name carried by the message.
+type Metrics interface {
+	// Connect records a dial, err is nil when it succeeded
+	Connect(err error)
+	// Reconnect records a successful reconnection
+	Reconnect()
+	// Publish records a publishing, err is nil when it succeeded
+	Publish(name string, err error)
+	// Confirm records a publisher confirmation from the server
+	Confirm(ack bool)
+	// Deliver records a delivery received by consumer
+	Deliver(name string)
+	Ack(name string)
+	Nack(name string)
+	Reject(name string)
+	// Handle records how long the executor took
+	Handle(name string, elapsed time.Duration)
+	// InFlight add delta to the deliveries being handled
+	InFlight(name string, delta int)
+}
+
+// NopMetrics records nothing
+type NopMetrics struct {
+}
+
+func (NopMetrics) Connect(error) {}
+
+func (NopMetrics) Reconnect() {}
+
+func (NopMetrics) Publish(string, error) {}
+
+func (NopMetrics) Confirm(bool) {}
+
+func (NopMetrics) Deliver(string) {}
+
+func (NopMetrics) Ack(string) {}
+
+func (NopMetrics) Nack(string) {}
+
+func (NopMetrics) Reject(string) {}
+
+func (NopMetrics) Handle(string, time.Duration) {}
+
+func (NopMetrics) InFlight(string, int) {}
@@ -0,0 +1,258 @@
+package mq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets handler latency buckets in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type PrometheusOption struct {
+	Namespace string
+	Buckets   []float64
+}
+
+// NewPrometheus returns Metrics which exports Prometheus text format by WriteTo or ServeHTTP,
+// it needs no prometheus client library.
+func NewPrometheus(opts ...func(*PrometheusOption)) *Prometheus {
+	o := PrometheusOption{
+		Namespace: "mq",
+		Buckets:   DefaultBuckets,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	buckets := append([]float64(nil), o.Buckets...)
+	sort.Float64s(buckets)
+	return &Prometheus{
+		namespace:   o.Namespace,
+		buckets:     buckets,
+		connections: make(map[string]float64),
+		publishes:   make(map[string]float64),
+		confirms:    make(map[string]float64),
+		deliveries:  make(map[string]float64),
+		acks:        make(map[string]float64),
+		nacks:       make(map[string]float64),
+		rejects:     make(map[string]float64),
+		inFlight:    make(map[string]float64),
+		handles:     make(map[string]*histogram),
+	}
+}
+
+type Prometheus struct {
+	namespace string
+	buckets   []float64
+
+	mutex       sync.Mutex
+	connections map[string]float64
+	reconnects  float64
+	publishes   map[string]float64
+	confirms    map[string]float64
+	deliveries  map[string]float64
+	acks        map[string]float64
+	nacks       map[string]float64
+	rejects     map[string]float64
+	inFlight    map[string]float64
+	handles     map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (p *Prometheus) Connect(err error) {
+	p.mutex.Lock()
+	p.connections[labels("result", result(err))]++
+	p.mutex.Unlock()
+}
+
+func (p *Prometheus) Reconnect() {
+	p.mutex.Lock()
+	p.reconnects++
+	p.mutex.Unlock()
+}
+
+func (p *Prometheus) Publish(name string, err error) {
+	p.mutex.Lock()
+	p.publishes[labels("name", name, "result", result(err))]++
+	p.mutex.Unlock()
+}
+
+func (p *Prometheus) Confirm(ack bool) {
+	value := "ack"
+	if !ack {
+		value = "nack"
+	}
+	p.mutex.Lock()
+	p.confirms[labels("result", value)]++
+	p.mutex.Unlock()
+}
+
+func (p *Prometheus) Deliver(name string) {
+	p.add(p.deliveries, name, 1)
+}
+
+func (p *Prometheus) Ack(name string) {
+	p.add(p.acks, name, 1)
+}
+
+func (p *Prometheus) Nack(name string) {
+	p.add(p.nacks, name, 1)
+}
+
+func (p *Prometheus) Reject(name string) {
+	p.add(p.rejects, name, 1)
+}
+
+func (p *Prometheus) InFlight(name string, delta int) {
+	p.add(p.inFlight, name, float64(delta))
+}
+
+func (p *Prometheus) Handle(name string, elapsed time.Duration) {
+	seconds := elapsed.Seconds()
+	key := labels("name", name)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	h, ok := p.handles[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(p.buckets))}
+		p.handles[key] = h
+	}
+	for i, bound := range p.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (p *Prometheus) add(m map[string]float64, name string, delta float64) {
+	p.mutex.Lock()
+	m[labels("name", name)] += delta
+	p.mutex.Unlock()
+}
+
+// WriteTo writes all metrics in Prometheus text exposition format
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	p.mutex.Lock()
+	p.writeVec(cw, "connections_total", "counter", "Number of dials to the broker.", p.connections)
+	p.writeVec(cw, "reconnects_total", "counter", "Number of successful reconnections.",
+		map[string]float64{"": p.reconnects})
+	p.writeVec(cw, "publishes_total", "counter", "Number of published messages.", p.publishes)
+	p.writeVec(cw, "confirms_total", "counter", "Number of publisher confirmations.", p.confirms)
+	p.writeVec(cw, "deliveries_total", "counter", "Number of received deliveries.", p.deliveries)
+	p.writeVec(cw, "acks_total", "counter", "Number of acknowledged deliveries.", p.acks)
+	p.writeVec(cw, "nacks_total", "counter", "Number of negatively acknowledged deliveries.", p.nacks)
+	p.writeVec(cw, "rejects_total", "counter", "Number of rejected deliveries.", p.rejects)
+	p.writeVec(cw, "in_flight", "gauge", "Number of deliveries being handled.", p.inFlight)
+	p.writeHistogram(cw, "handle_duration_seconds", "Executor handle latency in seconds.")
+	p.mutex.Unlock()
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = p.WriteTo(w)
+}
+
+func (p *Prometheus) writeVec(w *countWriter, name, typ, help string, m map[string]float64) {
+	name = p.namespace + "_" + name
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	for _, key := range sortedKeys(m) {
+		w.printf("%s%s %s\n", name, key, formatFloat(m[key]))
+	}
+}
+
+func (p *Prometheus) writeHistogram(w *countWriter, name, help string) {
+	name = p.namespace + "_" + name
+	w.printf("# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	keys := make([]string, 0, len(p.handles))
+	for key := range p.handles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		h := p.handles[key]
+		// key looks like {name="x"}, le is appended into the braces
+		prefix := strings.TrimSuffix(key, "}") + ","
+		for i, bound := range p.buckets {
+			w.printf("%s_bucket%sle=\"%s\"} %d\n", name, prefix, formatFloat(bound), h.counts[i])
+		}
+		w.printf("%s_bucket%sle=\"+Inf\"} %d\n", name, prefix, h.count)
+		w.printf("%s_sum%s %s\n", name, key, formatFloat(h.sum))
+		w.printf("%s_count%s %d\n", name, key, h.count)
+	}
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countWriter) printf(format string, a ...interface{}) {
+	if c.err != nil {
+		return
+	}
+	var n int
+	n, c.err = fmt.Fprintf(c.w, format, a...)
+	c.n += int64(n)
+}
+
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// labels build {k1="v1",k2="v2"} from key value pairs
+func labels(kv ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(kv[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(kv[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabel(v string) string {
+	return labelReplacer.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
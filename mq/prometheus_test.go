@@ -0,0 +1,58 @@
+package mq
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheus(t *testing.T) {
+	p := NewPrometheus(func(o *PrometheusOption) {
+		o.Buckets = []float64{1, 0.1}
+	})
+	p.Connect(nil)
+	p.Connect(errors.New("refused"))
+	p.Reconnect()
+	p.Publish("async.test", nil)
+	p.Publish("async.test", nil)
+	p.Confirm(false)
+	p.Deliver(`a"b`)
+	p.Ack("async.test")
+	p.Reject(UnknownName)
+	p.InFlight("async.test", 1)
+	p.InFlight("async.test", 1)
+	p.InFlight("async.test", -1)
+	p.Handle("async.test", 50*time.Millisecond)
+	p.Handle("async.test", 2*time.Second)
+
+	var buf bytes.Buffer
+	n, err := p.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(buf.Len()), n)
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE mq_connections_total counter",
+		`mq_connections_total{result="failure"} 1`,
+		`mq_connections_total{result="success"} 1`,
+		"mq_reconnects_total 1",
+		`mq_publishes_total{name="async.test",result="success"} 2`,
+		`mq_confirms_total{result="nack"} 1`,
+		`mq_deliveries_total{name="a\"b"} 1`,
+		`mq_acks_total{name="async.test"} 1`,
+		`mq_rejects_total{name="unknown"} 1`,
+		"# TYPE mq_in_flight gauge",
+		`mq_in_flight{name="async.test"} 1`,
+		"# TYPE mq_handle_duration_seconds histogram",
+		`mq_handle_duration_seconds_bucket{name="async.test",le="0.1"} 1`,
+		`mq_handle_duration_seconds_bucket{name="async.test",le="1"} 1`,
+		`mq_handle_duration_seconds_bucket{name="async.test",le="+Inf"} 2`,
+		`mq_handle_duration_seconds_sum{name="async.test"} 2.05`,
+		`mq_handle_duration_seconds_count{name="async.test"} 2`,
+	} {
+		require.True(t, strings.Contains(out, line+"\n"), "missing %q in\n%s", line, out)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"github.com/go-playground/validator/v10"
 )
 
-var sortCompile = regexp.MustCompile(`^[a-z][a-z_]{0,30}[a-z](\s(asc|ASC|desc|DESC))?(,[a-z][a-z_]{0,30}[a-z](\s(asc|ASC|desc|DESC))?)*$`)
+var sortCompile = regexp.MustCompile(`^` + sortField + `(,` + sortField + `)*$`)
+
+const sortField = `[a-z][a-z_]{0,30}[a-z](\s(asc|ASC|desc|DESC))?(\s(nulls|NULLS)\s(first|FIRST|last|LAST))?`
 
 func Sort(f1 validator.FieldLevel) bool {
 	valid, ok := f1.Field().Interface().(string)
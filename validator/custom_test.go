@@ -73,6 +73,20 @@ func TestSort(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "nulls_ok",
+			args: args{
+				value: "name asc nulls first,updated_at NULLS LAST",
+			},
+			wantErr: false,
+		},
+		{
+			name: "nulls_failed",
+			args: args{
+				value: "name nulls middle",
+			},
+			wantErr: true,
+		},
 		{
 			name: "mult_failed",
 			args: args{